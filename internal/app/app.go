@@ -0,0 +1,503 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/aweeting/internal/awtrix"
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/config"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+const (
+	ReloadTimeout   = 1 * time.Minute
+	ShutdownTimeout = 5 * time.Minute
+)
+
+// Loader loads the fresh config on reload
+type Loader func() (*config.Config, error)
+
+// App runs the pipeline of each profile and rebuilds them on config reload
+type App struct {
+	mu   sync.Mutex
+	load Loader
+	cfg  *config.Config
+	// runtime owns the MQTT connection and the storage shared between the pipelines
+	runtime   *config.Runtime
+	pipelines []*pipeline
+	topics    []string
+	errs      chan error
+}
+
+func NewApp(cfg *config.Config, load Loader) (*App, error) {
+	runtime, err := cfg.NewRuntime()
+	if err != nil {
+		return nil, fmt.Errorf("create runtime: %w", err)
+	}
+
+	a := &App{
+		load:    load,
+		cfg:     cfg,
+		runtime: runtime,
+		errs:    make(chan error, 1),
+	}
+
+	for _, pc := range cfg.Pipelines() {
+		p, err := a.newPipeline(runtime, pc)
+		if err != nil {
+			a.Stop(context.Background())
+			return nil, err
+		}
+
+		a.pipelines = append(a.pipelines, p)
+	}
+
+	return a, nil
+}
+
+func (a *App) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current := make([]*pipelineUpdate, len(a.pipelines))
+	for i, p := range a.pipelines {
+		current[i] = &pipelineUpdate{
+			pipeline: p,
+			cfg:      p.cfg,
+		}
+	}
+
+	topics, err := a.subscribe(a.runtime, current)
+	if err != nil {
+		return err
+	}
+
+	a.topics = topics
+	for _, p := range a.pipelines {
+		a.startTicker(p, p.tick)
+	}
+
+	return nil
+}
+
+// Errors returns the channel of the fatal errors
+func (a *App) Errors() <-chan error {
+	return a.errs
+}
+
+// Reload loads the config and rebuilds the affected components of each profile.
+// The current components are kept if the new config is invalid.
+func (a *App) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cfg, err := a.load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	runtime, err := cfg.NewRuntime()
+	if err != nil {
+		return fmt.Errorf("create runtime: %w", err)
+	}
+	runtime.Adopt(a.runtime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReloadTimeout)
+	defer cancel()
+
+	updates, removed, err := a.plan(runtime, cfg)
+	if err != nil {
+		runtime.Discard(a.runtime)
+		return err
+	}
+
+	changed := len(removed) > 0
+	for _, u := range updates {
+		changed = changed || !u.changes.IsZero()
+	}
+
+	if !changed {
+		log.Info().Msg("config reloaded, nothing changed")
+		return nil
+	}
+
+	discard := func() {
+		for _, u := range updates {
+			u.discard(ctx)
+		}
+		runtime.Discard(a.runtime)
+	}
+
+	resubscribe := len(removed) > 0
+	for _, u := range updates {
+		if err := u.build(ctx); err != nil {
+			discard()
+			return fmt.Errorf("profile %q: %w", u.cfg.Name, err)
+		}
+
+		resubscribe = resubscribe || u.changes.Mqtt || u.changes.Sinks
+	}
+
+	if resubscribe {
+		topics, err := a.subscribe(runtime, updates)
+		if err != nil {
+			discard()
+			return err
+		}
+
+		a.unsubscribe(ctx, runtime, topics)
+		a.topics = topics
+	}
+
+	pipelines := make([]*pipeline, 0, len(updates))
+	for _, u := range updates {
+		a.apply(ctx, u)
+		pipelines = append(pipelines, u.pipeline)
+	}
+
+	for _, p := range removed {
+		p.stop(ctx)
+		p.log.Info().Msg("profile removed")
+	}
+
+	a.runtime.CloseExcept(runtime)
+	a.cfg = cfg
+	a.runtime = runtime
+	a.pipelines = pipelines
+	log.Info().Int("profiles", len(pipelines)).Int("removed", len(removed)).Msg("config reloaded")
+	return nil
+}
+
+func (a *App) Stop(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, p := range a.pipelines {
+		p.stop(ctx)
+	}
+	a.runtime.Close()
+}
+
+func (a *App) newPipeline(runtime *config.Runtime, cfg *config.Config) (*pipeline, error) {
+	prt, err := runtime.Profile(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: create runtime: %w", cfg.Name, err)
+	}
+
+	p, err := newPipeline(cfg, prt)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", cfg.Name, err)
+	}
+
+	return p, nil
+}
+
+// plan returns the updates of each profile in the new config order and the removed pipelines
+func (a *App) plan(runtime *config.Runtime, cfg *config.Config) ([]*pipelineUpdate, []*pipeline, error) {
+	current := make(map[string]*pipeline, len(a.pipelines))
+	for _, p := range a.pipelines {
+		current[p.name] = p
+	}
+
+	var updates []*pipelineUpdate
+	for _, pc := range cfg.Pipelines() {
+		prt, err := runtime.Profile(pc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("profile %q: create runtime: %w", pc.Name, err)
+		}
+
+		p, ok := current[pc.Name]
+		delete(current, pc.Name)
+		if !ok {
+			updates = append(updates, &pipelineUpdate{
+				pipeline: &pipeline{
+					name: pc.Name,
+					log:  log.With().Str("profile", pc.Name).Logger(),
+				},
+				cfg:     pc,
+				runtime: prt,
+				changes: config.Changes{Mqtt: true, Sinks: true, Ticker: true},
+			})
+			continue
+		}
+
+		prt.Adopt(p.runtime)
+		updates = append(updates, &pipelineUpdate{
+			pipeline: p,
+			cfg:      pc,
+			runtime:  prt,
+			changes:  pc.Changes(p.cfg),
+		})
+	}
+
+	removed := make([]*pipeline, 0, len(current))
+	for _, p := range a.pipelines {
+		if _, ok := current[p.name]; ok {
+			removed = append(removed, p)
+		}
+	}
+
+	return updates, removed, nil
+}
+
+// apply swaps the pipeline components with the built ones
+func (a *App) apply(ctx context.Context, u *pipelineUpdate) {
+	p := u.pipeline
+	p.cfg = u.cfg
+	p.runtime = u.runtime
+	if u.changes.IsZero() {
+		return
+	}
+
+	if u.sinks != nil {
+		if prev := p.sinks.Swap(u.sinks); prev != nil {
+			prev.Close(ctx)
+		}
+	}
+
+	switch {
+	case u.tick != nil:
+		if p.started {
+			p.tick.Stop(ctx)
+		}
+
+		p.tick = u.tick
+		a.startTicker(p, u.tick)
+	default:
+		if err := p.tick.Tick(ctx); err != nil {
+			p.log.Error().Err(err).Msg("tick after reload failed")
+		}
+	}
+
+	p.log.Info().
+		Bool("mqtt", u.changes.Mqtt).
+		Bool("sinks", u.changes.Sinks).
+		Bool("ticker", u.changes.Ticker).
+		Msg("profile reloaded")
+}
+
+// subscribe subscribes to the manual overrides commands, the awtrix buttons and stats of each pipeline with its pending config,
+// returns the subscribed topics. The state is republished once the device or the MQTT connection is back online
+func (a *App) subscribe(runtime *config.Runtime, pipelines []*pipelineUpdate) ([]string, error) {
+	handlers := make(map[string]broker.MessageHandler)
+	owners := make(map[string]string)
+	devices := make(map[string][]*pipeline)
+	add := func(p *pipeline, topic string, handler broker.MessageHandler) error {
+		if owner, ok := owners[topic]; ok {
+			return fmt.Errorf("topic %q is used by both %q and %q profiles", topic, owner, p.name)
+		}
+
+		owners[topic] = p.name
+		handlers[topic] = handler
+		return nil
+	}
+
+	for _, u := range pipelines {
+		p := u.pipeline
+		cfg := u.cfg
+
+		if cfg.Mqtt.CommandTopic != "" {
+			err := add(p, cfg.Mqtt.CommandTopic, func(payload []byte) {
+				cmd, err := ticker.ParseCommand(payload)
+				if err != nil {
+					p.log.Warn().Str("name", "commands").Err(err).Msg("ignore command")
+					return
+				}
+
+				a.execute(p, cmd)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		buttons, err := cfg.Awtrix.Buttons.Commands()
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: invalid buttons: %w", p.name, err)
+		}
+
+		for _, prefix := range cfg.AwtrixPrefixes() {
+			// the device may be shared between the profiles, so all of them must be republished
+			devices[prefix] = append(devices[prefix], p)
+			for button, cmd := range buttons {
+				cmd := cmd
+				err := add(p, awtrix.ButtonTopic(prefix, button), func(payload []byte) {
+					if awtrix.IsPressed(payload) {
+						a.execute(p, cmd)
+					}
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for prefix, owners := range devices {
+		owners := owners
+		watcher := awtrix.NewDeviceWatcher(awtrix.DefaultOfflineAfter)
+		handlers[awtrix.StatsTopic(prefix)] = func(payload []byte) {
+			if !watcher.Online(payload, time.Now()) {
+				return
+			}
+
+			for _, p := range owners {
+				a.republish(p, "device online")
+			}
+		}
+	}
+
+	// the sinks may use the connection w/o any subscriptions, they still need the republish on reconnect
+	client := runtime.OpenedMqtt()
+	if len(handlers) > 0 {
+		var err error
+		client, err = runtime.MqttClient()
+		if err != nil {
+			return nil, fmt.Errorf("create mqtt client: %w", err)
+		}
+	}
+
+	if client == nil {
+		return nil, nil
+	}
+
+	client.OnReconnect(func() {
+		a.mu.Lock()
+		pipelines := a.pipelines
+		a.mu.Unlock()
+
+		for _, p := range pipelines {
+			a.republish(p, "mqtt reconnected")
+		}
+	})
+
+	if len(handlers) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReloadTimeout)
+	defer cancel()
+
+	topics := make([]string, 0, len(handlers))
+	for topic, handler := range handlers {
+		if err := client.Subscribe(ctx, topic, handler); err != nil {
+			return nil, fmt.Errorf("subscribe to %q: %w", topic, err)
+		}
+
+		topics = append(topics, topic)
+	}
+
+	return topics, nil
+}
+
+// unsubscribe drops the stale subscriptions if the MQTT client is shared with the new runtime,
+// otherwise they die along with the old client
+func (a *App) unsubscribe(ctx context.Context, runtime *config.Runtime, keep []string) {
+	if len(a.topics) == 0 || !runtime.SharesMqtt(a.runtime) {
+		return
+	}
+
+	client, err := a.runtime.MqttClient()
+	if err != nil {
+		return
+	}
+
+	kept := make(map[string]struct{}, len(keep))
+	for _, topic := range keep {
+		kept[topic] = struct{}{}
+	}
+
+	for _, topic := range a.topics {
+		if _, ok := kept[topic]; ok {
+			continue
+		}
+
+		if err := client.Unsubscribe(ctx, topic); err != nil {
+			log.Warn().Err(err).Str("topic", topic).Msg("unsubscribe failed")
+		}
+	}
+}
+
+// execute applies the manual command and ticks to show the result immediately
+func (a *App) execute(p *pipeline, cmd ticker.Command) {
+	l := p.log.With().Str("name", "commands").Str("action", string(cmd.Action)).Logger()
+
+	// the command may fetch the calendar, so don't block the reloads meanwhile
+	tick, ok := a.activeTicker(p)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReloadTimeout)
+	defer cancel()
+
+	if err := tick.Execute(ctx, cmd); err != nil {
+		l.Error().Err(err).Msg("command failed")
+		return
+	}
+
+	l.Info().Msg("command applied")
+}
+
+// republish resends the current state to the device immediately
+func (a *App) republish(p *pipeline, reason string) {
+	tick, ok := a.activeTicker(p)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ReloadTimeout)
+	defer cancel()
+
+	if err := tick.Republish(ctx); err != nil {
+		p.log.Error().Err(err).Str("reason", reason).Msg("republish failed")
+		return
+	}
+
+	p.log.Info().Str("reason", reason).Msg("state republished")
+}
+
+// activeTicker returns the pipeline ticker if the pipeline is running
+func (a *App) activeTicker(p *pipeline) (ticker.Ticker, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if p.stopped || !p.started {
+		return nil, false
+	}
+
+	return p.tick, true
+}
+
+func (a *App) startTicker(p *pipeline, tick ticker.Ticker) {
+	p.started = true
+	go func() {
+		err := tick.Start(p.handle)
+		if err == nil {
+			return
+		}
+
+		a.mu.Lock()
+		replaced := p.stopped || p.tick != tick
+		a.mu.Unlock()
+
+		// the replaced ticker may fail due to the stop in the middle of the start
+		if replaced {
+			return
+		}
+
+		a.fail(fmt.Errorf("profile %q: failed to start ticker: %w", p.name, err))
+	}()
+}
+
+func (a *App) fail(err error) {
+	select {
+	case a.errs <- err:
+	default:
+	}
+}
@@ -0,0 +1,317 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/config"
+	"github.com/buglloc/aweeting/internal/sink"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+type received struct {
+	Version string
+	Message sink.Message
+}
+
+// testEnv is the calendar file with the meeting in progress, the webhook receiver and the config file pointing to them
+type testEnv struct {
+	dir      string
+	config   string
+	webhook  string
+	messages chan received
+	// calendar serves the calendar file over HTTP counting the fetches
+	calendar string
+	fetches  atomic.Int64
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	dir := t.TempDir()
+	start := time.Now().UTC().Add(-10 * time.Minute)
+	ics := fmt.Sprintf(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//aweeting//test//EN
+BEGIN:VEVENT
+UID:daily
+SUMMARY:Daily
+DTSTART:%s
+DTEND:%s
+END:VEVENT
+END:VCALENDAR
+`, start.Format("20060102T150405Z"), start.Add(time.Hour).Format("20060102T150405Z"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "calendar.ics"), []byte(ics), 0o600))
+
+	env := &testEnv{
+		dir:      dir,
+		config:   filepath.Join(dir, "config.yaml"),
+		messages: make(chan received, 64),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg sink.Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		env.messages <- received{
+			Version: r.Header.Get("X-Version"),
+			Message: msg,
+		}
+	}))
+	t.Cleanup(srv.Close)
+	env.webhook = srv.URL
+
+	cal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env.fetches.Add(1)
+		http.ServeFile(w, r, filepath.Join(dir, "calendar.ics"))
+	}))
+	t.Cleanup(cal.Close)
+	env.calendar = cal.URL + "/calendar.ics"
+	return env
+}
+
+// write writes the config with the given webhook version header and the extra ticker settings
+func (e *testEnv) write(t *testing.T, version string, tickerExtra string) {
+	e.writeSource(t, filepath.Join(e.dir, "calendar.ics"), version, tickerExtra)
+}
+
+func (e *testEnv) writeSource(t *testing.T, source string, version string, tickerExtra string) {
+	require.NoError(t, os.WriteFile(e.config, []byte(e.body(source, version, tickerExtra)), 0o600))
+}
+
+func (e *testEnv) body(source string, version string, tickerExtra string) string {
+	return fmt.Sprintf(`
+calendar:
+  sourceUrl: %s
+  timezone: UTC
+ticker:
+  tickInterval: 1h
+%s
+sinks:
+  - kind: webhook
+    url: %s
+    headers:
+      X-Version: %s
+`, source, tickerExtra, e.webhook, version)
+}
+
+func (e *testEnv) load() (*config.Config, error) {
+	return config.LoadConfig(e.config)
+}
+
+func (e *testEnv) next(t *testing.T, version string) sink.Message {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case r := <-e.messages:
+			if r.Version == version {
+				return r.Message
+			}
+		case <-timeout:
+			t.Fatalf("no message from the %q config", version)
+		}
+	}
+}
+
+func newTestApp(t *testing.T, env *testEnv) *App {
+	cfg, err := env.load()
+	require.NoError(t, err)
+
+	a, err := NewApp(cfg, env.load)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		a.Stop(ctx)
+	})
+
+	require.NoError(t, a.Start())
+	return a
+}
+
+func TestApp_Reload(t *testing.T) {
+	env := newTestEnv(t)
+	env.write(t, "v1", "")
+	a := newTestApp(t, env)
+
+	msg := env.next(t, "v1")
+	require.Equal(t, ticker.StateOnAir, msg.State)
+
+	// nothing changed
+	require.NoError(t, a.Reload())
+
+	// the rebuilt ticker continues from the current state, so no false "started" transition
+	env.write(t, "v2", "  wrappingUp: 1m")
+	require.NoError(t, a.Reload())
+	msg = env.next(t, "v2")
+	require.Equal(t, ticker.StateOnAir, msg.State)
+	require.Equal(t, ticker.StateOnAir, msg.PrevState)
+
+	// the invalid config keeps the current pipelines
+	require.NoError(t, os.WriteFile(env.config, []byte("sinks:\n  - kind: bogus\n"), 0o600))
+	require.Error(t, a.Reload())
+	require.Equal(t, env.webhook, a.cfg.Sinks[0].URL)
+
+	env.write(t, "v3", "")
+	require.NoError(t, a.Reload())
+	msg = env.next(t, "v3")
+	require.Equal(t, ticker.StateOnAir, msg.PrevState)
+}
+
+func TestApp_ReloadStopsTicker(t *testing.T) {
+	env := newTestEnv(t)
+	env.writeSource(t, env.calendar, "v1", "  fetchInterval: 20ms")
+	a := newTestApp(t, env)
+	env.next(t, "v1")
+
+	// the rebuilt ticker replaces the old one, which must stop fetching the calendar
+	env.writeSource(t, env.calendar, "v2", "  fetchInterval: 20ms\n  wrappingUp: 1m")
+	require.NoError(t, a.Reload())
+	env.next(t, "v2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	a.Stop(ctx)
+
+	// the in-flight fetch may still finish
+	time.Sleep(50 * time.Millisecond)
+	stopped := env.fetches.Load()
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, stopped, env.fetches.Load())
+}
+
+// serveBroker is the broker stand-in acknowledging the connections and forwarding them to drop later
+func serveBroker(t *testing.T) (string, <-chan net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			conns <- conn
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+
+				for {
+					p, err := packets.ReadPacket(conn)
+					if err != nil {
+						return
+					}
+
+					var reply packets.ControlPacket
+					switch p.(type) {
+					case *packets.ConnectPacket:
+						reply = packets.NewControlPacket(packets.Connack)
+					case *packets.PingreqPacket:
+						reply = packets.NewControlPacket(packets.Pingresp)
+					case *packets.DisconnectPacket:
+						return
+					}
+
+					if reply != nil {
+						if err := reply.Write(conn); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+
+	return "tcp://" + ln.Addr().String(), conns
+}
+
+func TestApp_ReconnectRepublishes(t *testing.T) {
+	env := newTestEnv(t)
+	upstream, conns := serveBroker(t)
+
+	// the state sink publishes over MQTT w/o any subscriptions
+	body := env.body(filepath.Join(env.dir, "calendar.ics"), "v1", "") + fmt.Sprintf(`
+  - kind: state
+    topic: aweeting/state
+mqtt:
+  upstream: %s
+`, upstream)
+	require.NoError(t, os.WriteFile(env.config, []byte(body), 0o600))
+
+	newTestApp(t, env)
+	env.next(t, "v1")
+
+	// drop the connection on the broker side
+	_ = (<-conns).Close()
+
+	msg := env.next(t, "v1")
+	require.Equal(t, ticker.StateOnAir, msg.State)
+}
+
+func TestApp_Watch(t *testing.T) {
+	env := newTestEnv(t)
+	env.write(t, "v1", "")
+	a := newTestApp(t, env)
+	env.next(t, "v1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, a.Watch(ctx, []string{env.config}))
+
+	env.write(t, "v2", "")
+	msg := env.next(t, "v2")
+	require.Equal(t, ticker.StateOnAir, msg.State)
+}
+
+func TestApp_WatchConfigMap(t *testing.T) {
+	env := newTestEnv(t)
+
+	// the configmap mount: config.yaml -> ..data/config.yaml, ..data -> ..<version>
+	mount := filepath.Join(env.dir, "configmap")
+	update := func(version string) {
+		data := env.body(filepath.Join(env.dir, "calendar.ics"), version, "")
+		dir := filepath.Join(mount, ".."+version)
+		require.NoError(t, os.MkdirAll(dir, 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(data), 0o600))
+
+		tmp := filepath.Join(mount, "..data_tmp")
+		require.NoError(t, os.Symlink(".."+version, tmp))
+		require.NoError(t, os.Rename(tmp, filepath.Join(mount, "..data")))
+	}
+
+	require.NoError(t, os.MkdirAll(mount, 0o700))
+	update("v1")
+	env.config = filepath.Join(mount, "config.yaml")
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config.yaml"), env.config))
+
+	a := newTestApp(t, env)
+	env.next(t, "v1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, a.Watch(ctx, []string{env.config}))
+
+	update("v2")
+	msg := env.next(t, "v2")
+	require.Equal(t, ticker.StateOnAir, msg.State)
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/aweeting/internal/calendar"
+	"github.com/buglloc/aweeting/internal/config"
+	"github.com/buglloc/aweeting/internal/sink"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+// pipeline is the calendar -> display chain of a single profile
+type pipeline struct {
+	name    string
+	cfg     *config.Config
+	runtime *config.Runtime
+	sinks   atomic.Pointer[sink.Registry]
+	tick    ticker.Ticker
+	started bool
+	stopped bool
+	log     zerolog.Logger
+}
+
+// pipelineUpdate is the pending pipeline change applied on reload
+type pipelineUpdate struct {
+	pipeline *pipeline
+	cfg      *config.Config
+	runtime  *config.Runtime
+	changes  config.Changes
+	sinks    *sink.Registry
+	tick     ticker.Ticker
+}
+
+func newPipeline(cfg *config.Config, runtime *config.Runtime) (*pipeline, error) {
+	sinks, err := runtime.NewSinks()
+	if err != nil {
+		return nil, fmt.Errorf("create sinks: %w", err)
+	}
+
+	tick, err := runtime.NewTicker()
+	if err != nil {
+		sinks.Close(context.Background())
+		return nil, fmt.Errorf("create ticker: %w", err)
+	}
+
+	p := &pipeline{
+		name:    cfg.Name,
+		cfg:     cfg,
+		runtime: runtime,
+		tick:    tick,
+		log:     log.With().Str("profile", cfg.Name).Logger(),
+	}
+	p.sinks.Store(sinks)
+	return p, nil
+}
+
+// build creates the components affected by the changes, makes sure the new calendar is usable
+func (u *pipelineUpdate) build(ctx context.Context) error {
+	if u.changes.Sinks {
+		sinks, err := u.runtime.NewSinks()
+		if err != nil {
+			return fmt.Errorf("create sinks: %w", err)
+		}
+
+		u.sinks = sinks
+	}
+
+	if !u.changes.Ticker {
+		return nil
+	}
+
+	tick, err := u.runtime.NewTicker()
+	if err != nil {
+		u.discard(ctx)
+		return fmt.Errorf("create ticker: %w", err)
+	}
+
+	cal, err := u.runtime.NewCalendar()
+	if err == nil {
+		_, err = cal.Events(ctx, calendar.DefaultLimit)
+	}
+
+	if err != nil {
+		u.discard(ctx)
+		return fmt.Errorf("check calendar: %w", err)
+	}
+
+	u.tick = tick
+	return nil
+}
+
+// discard closes the built components which weren't applied
+func (u *pipelineUpdate) discard(ctx context.Context) {
+	if u.sinks != nil {
+		u.sinks.Close(ctx)
+		u.sinks = nil
+	}
+}
+
+func (p *pipeline) handle(ctx context.Context, event ticker.Event) error {
+	sinks := p.sinks.Load()
+	if sinks == nil {
+		return errors.New("no sinks")
+	}
+
+	return sinks.Handle(ctx, event)
+}
+
+func (p *pipeline) stop(ctx context.Context) {
+	p.stopped = true
+	if p.started {
+		p.tick.Stop(ctx)
+	}
+
+	p.sinks.Load().Close(ctx)
+}
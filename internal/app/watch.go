@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+const WatchDebounce = 1 * time.Second
+
+// configMapData is the symlink k8s swaps to update the mounted configmap files at once
+const configMapData = "..data"
+
+// Watch reloads the app on the config files change until the ctx is canceled.
+// Parent directories are watched, since editors replace files instead of writing them
+// and k8s configmaps swap the "..data" symlink the files point through.
+func (a *App) Watch(ctx context.Context, files []string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+
+	watched := make(map[string]struct{}, 2*len(files))
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("resolve %q: %w", f, err)
+		}
+
+		watched[abs] = struct{}{}
+		watched[filepath.Join(filepath.Dir(abs), configMapData)] = struct{}{}
+		if err := watcher.Add(filepath.Dir(abs)); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("watch %q: %w", f, err)
+		}
+	}
+
+	go func() {
+		defer func() { _ = watcher.Close() }()
+
+		l := log.With().Str("name", "watcher").Logger()
+		debounce := time.NewTimer(WatchDebounce)
+		debounce.Stop()
+		defer debounce.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				l.Warn().Err(err).Msg("watch failed")
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if _, ok := watched[ev.Name]; !ok || ev.Has(fsnotify.Chmod) {
+					continue
+				}
+
+				debounce.Reset(WatchDebounce)
+			case <-debounce.C:
+				l.Info().Msg("config changed, reloading")
+				if err := a.Reload(); err != nil {
+					l.Error().Err(err).Msg("reload failed, keep the current config")
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package awtrix
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/storage"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+const DefaultAlertsStorageKey = "awtrix.alerts"
+
+type AlerterConfig struct {
+	// Offsets before the interval start to fire an alert at
+	Offsets []time.Duration
+	Storage *storage.Storage
+	// StorageKey to keep fired alerts at, must be unique per device
+	StorageKey string
+}
+
+type Alert struct {
+	IntervalID string        `json:"-"`
+	StartsAt   time.Time     `json:"startsAt"`
+	Offset     time.Duration `json:"offset"`
+}
+
+// Alerter tracks pre-meeting alerts, so each of them is fired exactly once per interval
+type Alerter struct {
+	mu      sync.Mutex
+	offsets []time.Duration
+	storage *storage.Storage
+	key     string
+	fired   map[string]Alert
+}
+
+func NewAlerter(cfg AlerterConfig) (*Alerter, error) {
+	offsets := make([]time.Duration, 0, len(cfg.Offsets))
+	for _, o := range cfg.Offsets {
+		if o <= 0 {
+			return nil, fmt.Errorf("invalid alert offset: %s", o)
+		}
+
+		offsets = append(offsets, o)
+	}
+
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] < offsets[j]
+	})
+
+	a := &Alerter{
+		offsets: offsets,
+		storage: cfg.Storage,
+		key:     cfg.StorageKey,
+		fired:   make(map[string]Alert),
+	}
+
+	if a.key == "" {
+		a.key = DefaultAlertsStorageKey
+	}
+
+	if a.storage != nil {
+		if _, err := a.storage.Get(a.key, &a.fired); err != nil {
+			return nil, fmt.Errorf("load fired alerts: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+// Pending returns the alert to be fired for the event, if any.
+// Only the nearest offset is fired, so the restart in the middle of the countdown doesn't produce a burst of alerts.
+func (a *Alerter) Pending(event ticker.Event) (Alert, bool) {
+	if event.IsZero() || !event.Upcoming || len(a.offsets) == 0 {
+		return Alert{}, false
+	}
+
+	idx := sort.Search(len(a.offsets), func(i int) bool {
+		return a.offsets[i] >= event.ToStart
+	})
+	if idx == len(a.offsets) {
+		return Alert{}, false
+	}
+
+	alert := Alert{
+		IntervalID: event.IntervalID(),
+		StartsAt:   event.StartsAt,
+		Offset:     a.offsets[idx],
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if fired, ok := a.fired[alert.IntervalID]; ok && fired.Offset <= alert.Offset {
+		return Alert{}, false
+	}
+
+	return alert, true
+}
+
+// Ack marks the alert as fired
+func (a *Alerter) Ack(alert Alert) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.fired[alert.IntervalID] = alert
+	for id, fired := range a.fired {
+		if fired.StartsAt.Before(alert.StartsAt) {
+			delete(a.fired, id)
+		}
+	}
+
+	if a.storage == nil {
+		return nil
+	}
+
+	return a.storage.Set(a.key, a.fired)
+}
@@ -0,0 +1,120 @@
+package awtrix
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/storage"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestAlerter(t *testing.T) {
+	now := time.Unix(544672800, 0)
+	newEvent := func(toStart time.Duration) ticker.Event {
+		return ticker.Interval{
+			Start: now.Add(toStart),
+			End:   now.Add(toStart + time.Hour),
+		}.ToEvent(now)
+	}
+
+	cases := []struct {
+		name     string
+		fired    []time.Duration
+		toStart  time.Duration
+		expected time.Duration
+	}{
+		{
+			name:    "too-early",
+			toStart: 20 * time.Minute,
+		},
+		{
+			name:     "first",
+			toStart:  10 * time.Minute,
+			expected: 10 * time.Minute,
+		},
+		{
+			name:     "nearest",
+			toStart:  5 * time.Minute,
+			expected: 10 * time.Minute,
+		},
+		{
+			name:    "already-fired",
+			fired:   []time.Duration{10 * time.Minute},
+			toStart: 5 * time.Minute,
+		},
+		{
+			name:     "next",
+			fired:    []time.Duration{10 * time.Minute},
+			toStart:  time.Minute,
+			expected: time.Minute,
+		},
+		{
+			name:     "skip-missed",
+			toStart:  30 * time.Second,
+			expected: time.Minute,
+		},
+		{
+			name:    "started",
+			toStart: -time.Minute,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := NewAlerter(AlerterConfig{
+				Offsets: []time.Duration{time.Minute, 10 * time.Minute},
+			})
+			require.NoError(t, err)
+
+			event := newEvent(tc.toStart)
+			for _, o := range tc.fired {
+				require.NoError(t, a.Ack(Alert{
+					IntervalID: event.IntervalID(),
+					StartsAt:   event.StartsAt,
+					Offset:     o,
+				}))
+			}
+
+			alert, ok := a.Pending(event)
+			if tc.expected == 0 {
+				require.False(t, ok)
+				return
+			}
+
+			require.True(t, ok)
+			require.Equal(t, tc.expected, alert.Offset)
+		})
+	}
+}
+
+func TestAlerter_persist(t *testing.T) {
+	now := time.Unix(544672800, 0)
+	event := ticker.Interval{
+		Start: now.Add(5 * time.Minute),
+		End:   now.Add(time.Hour),
+	}.ToEvent(now)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	newAlerter := func() *Alerter {
+		s, err := storage.NewStorage(path)
+		require.NoError(t, err)
+
+		a, err := NewAlerter(AlerterConfig{
+			Offsets: []time.Duration{10 * time.Minute},
+			Storage: s,
+		})
+		require.NoError(t, err)
+		return a
+	}
+
+	a := newAlerter()
+	alert, ok := a.Pending(event)
+	require.True(t, ok)
+	require.NoError(t, a.Ack(alert))
+
+	_, ok = newAlerter().Pending(event)
+	require.False(t, ok)
+}
@@ -0,0 +1,23 @@
+package awtrix
+
+import (
+	"bytes"
+)
+
+// Button is the physical awtrix button, its state is published to <prefix>/stats/<button>
+type Button string
+
+const (
+	ButtonLeft   Button = "buttonLeft"
+	ButtonSelect Button = "buttonSelect"
+	ButtonRight  Button = "buttonRight"
+)
+
+func ButtonTopic(prefix string, button Button) string {
+	return prefix + "/stats/" + string(button)
+}
+
+// IsPressed reports whether the button state payload is the press, awtrix sends "1" on press and "0" on release
+func IsPressed(payload []byte) bool {
+	return string(bytes.TrimSpace(payload)) == "1"
+}
@@ -2,23 +2,13 @@ package awtrix
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
-	"time"
-
-	mqtt "github.com/eclipse/paho.mqtt.golang"
-	"github.com/rs/zerolog/log"
 
+	"github.com/buglloc/aweeting/internal/broker"
 	"github.com/buglloc/aweeting/internal/ticker"
 )
 
-const (
-	DefaultUpcomingLimit  = 8 * time.Hour
-	MqttConnectionTimeout = 5 * time.Minute
-)
-
 var DefaultPayload = Payload{
 	TextCase:    0,
 	Color:       "#ffffff",
@@ -30,120 +20,77 @@ var DefaultPayload = Payload{
 }
 
 type UpdaterConfig struct {
-	Upstream        string
-	Username        string
-	Password        string
-	Topic           string
-	SelfDestruct    bool
-	UpcomingLimit   time.Duration
-	NonePayload     Payload
-	UpcomingPayload Payload
-	OnAirPayload    Payload
+	Client    *broker.Client
+	Topic     string
+	Prefix    string
+	Formatter *Formatter
+	Notifier  *Notifier
+	// Controls drive the device settings by the events, optional
+	Controls []Controller
+	// Retain the app messages, so the device gets them back after reboot
+	Retain bool
 }
 
 type MqttUpdater struct {
-	mqtt mqtt.Client
+	mqtt *broker.Client
 	cfg  UpdaterConfig
+	delivery
 }
 
 func NewMqttUpdater(cfg UpdaterConfig) (*MqttUpdater, error) {
-	if cfg.Topic == "" {
-		return nil, errors.New(".Topic is required")
-	}
-
-	if cfg.Upstream == "" {
-		return nil, errors.New(".Upstream is required")
+	if cfg.Client == nil {
+		return nil, errors.New(".Client is required")
 	}
 
-	l := log.With().Str("name", "awtrix.mqtt").Logger()
-
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(cfg.Upstream)
-	if cfg.Username != "" {
-		opts.SetUsername(cfg.Username)
-		opts.SetPassword(cfg.Password)
+	if cfg.Topic == "" {
+		return nil, errors.New(".Topic is required")
 	}
 
-	opts.SetClientID("aweeting")
-	opts.SetAutoReconnect(true)
-	opts.OnConnect = func(_ mqtt.Client) {
-		l.Info().Msg("connected")
-	}
-	opts.OnConnectionLost = func(_ mqtt.Client, err error) {
-		l.Warn().Err(err).Msg("disconnected")
-	}
-	opts.OnReconnecting = func(_ mqtt.Client, _ *mqtt.ClientOptions) {
-		l.Info().Msg("reconnecting")
+	if cfg.Formatter == nil {
+		return nil, errors.New(".Formatter is required")
 	}
 
-	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.WaitTimeout(MqttConnectionTimeout) && token.Error() != nil {
-		return nil, fmt.Errorf("MQTT connection failed: %w", token.Error())
+	if (cfg.Notifier != nil || len(cfg.Controls) > 0) && cfg.Prefix == "" {
+		return nil, errors.New(".Prefix is required for notifications and controls")
 	}
 
-	return &MqttUpdater{
-		mqtt: client,
+	u := &MqttUpdater{
+		mqtt: cfg.Client,
 		cfg:  cfg,
-	}, nil
-}
-
-func (u *MqttUpdater) Update(ctx context.Context, event ticker.Event) error {
-	payloadBytes, err := u.payloadBytes(event)
-	if err != nil {
-		return fmt.Errorf("payload marshal: %w", err)
 	}
-
-	token := u.mqtt.Publish(u.cfg.Topic, 0, false, payloadBytes)
-	select {
-	case <-token.Done():
-		return token.Error()
-	case <-ctx.Done():
-		return fmt.Errorf("canceled: %w", ctx.Err())
-	}
-}
-
-func (u *MqttUpdater) payloadBytes(event ticker.Event) ([]byte, error) {
-	var payload Payload
-	switch {
-	case u.isNoneEvent(event):
-		if u.cfg.SelfDestruct {
-			return nil, nil
-		}
-
-		payload = u.cfg.NonePayload
-	case event.Upcoming:
-		payload = u.cfg.UpcomingPayload
-	default:
-		payload = u.cfg.OnAirPayload
+	u.delivery = delivery{
+		formatter: cfg.Formatter,
+		notifier:  cfg.Notifier,
+		controls:  cfg.Controls,
+		dedup:     NewDedup(),
+		app: func(ctx context.Context, payload []byte) error {
+			return u.publish(ctx, cfg.Topic, cfg.Retain, payload)
+		},
+		send: func(ctx context.Context, endpoint string, payload []byte) error {
+			return u.publish(ctx, cfg.Prefix+"/"+endpoint, false, payload)
+		},
 	}
-
-	payload.Text = u.eventText(event)
-	return json.Marshal(payload)
+	return u, nil
 }
 
-func (u *MqttUpdater) eventText(event ticker.Event) string {
-	switch {
-	case u.isNoneEvent(event):
-		return " ##:##"
-	case event.Upcoming:
-		return fmt.Sprintf("-%s", u.formatDuration(event.ToStart))
-	default:
-		return fmt.Sprintf(" %s", u.formatDuration(event.Left))
-	}
+func (u *MqttUpdater) Update(ctx context.Context, event ticker.Event) error {
+	return u.update(ctx, event)
 }
 
-func (u *MqttUpdater) formatDuration(d time.Duration) string {
-	if d.Minutes() < 60.0 {
-		return fmt.Sprintf("00:%02d", int(d.Minutes()))
+// Close restores the device settings and removes the retained app, so the device doesn't get the stale one after reboot
+func (u *MqttUpdater) Close(ctx context.Context) error {
+	err := u.close(ctx)
+	if !u.cfg.Retain {
+		return err
 	}
 
-	if d.Hours() < 24.0 {
-		remainingMinutes := math.Mod(d.Minutes(), 60)
-		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(remainingMinutes))
+	if rerr := u.publish(ctx, u.cfg.Topic, true, nil); rerr != nil {
+		err = errors.Join(err, fmt.Errorf("remove retained app: %w", rerr))
 	}
 
-	return "##:##"
+	return err
 }
-func (u *MqttUpdater) isNoneEvent(event ticker.Event) bool {
-	return event.IsZero() || time.Until(event.StartsAt) > u.cfg.UpcomingLimit
+
+func (u *MqttUpdater) publish(ctx context.Context, topic string, retain bool, payload []byte) error {
+	return u.mqtt.Publish(ctx, topic, retain, payload)
 }
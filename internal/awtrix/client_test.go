@@ -0,0 +1,130 @@
+package awtrix
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+type mqttMessage struct {
+	Topic   string
+	Retain  bool
+	Payload string
+}
+
+// serveBroker is the broker stand-in forwarding the published messages
+func serveBroker(t *testing.T) (string, <-chan mqttMessage) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	published := make(chan mqttMessage, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+
+				for {
+					p, err := packets.ReadPacket(conn)
+					if err != nil {
+						return
+					}
+
+					var reply packets.ControlPacket
+					switch p := p.(type) {
+					case *packets.ConnectPacket:
+						reply = packets.NewControlPacket(packets.Connack)
+					case *packets.PingreqPacket:
+						reply = packets.NewControlPacket(packets.Pingresp)
+					case *packets.PublishPacket:
+						published <- mqttMessage{
+							Topic:   p.TopicName,
+							Retain:  p.Retain,
+							Payload: string(p.Payload),
+						}
+					case *packets.DisconnectPacket:
+						return
+					}
+
+					if reply != nil {
+						if err := reply.Write(conn); err != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+
+	return "tcp://" + ln.Addr().String(), published
+}
+
+func nextMessage(t *testing.T, published <-chan mqttMessage) mqttMessage {
+	select {
+	case msg := <-published:
+		return msg
+	case <-time.After(10 * time.Second):
+		t.Fatal("nothing was published")
+		return mqttMessage{}
+	}
+}
+
+func TestMqttUpdater_Close(t *testing.T) {
+	upstream, published := serveBroker(t)
+	client, err := broker.NewClient(broker.Config{
+		Upstream: upstream,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	formatter, err := NewFormatter(FormatterConfig{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	event := ticker.Event{Now: time.Unix(544672800, 0), State: ticker.StateIdle}
+	for _, retain := range []bool{true, false} {
+		u, err := NewMqttUpdater(UpdaterConfig{
+			Client:    client,
+			Topic:     "awtrix/custom/meetings",
+			Formatter: formatter,
+			Retain:    retain,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, u.Update(ctx, event))
+		msg := nextMessage(t, published)
+		require.Equal(t, "awtrix/custom/meetings", msg.Topic)
+		require.Equal(t, retain, msg.Retain)
+		require.NotEmpty(t, msg.Payload)
+
+		require.NoError(t, u.Close(ctx))
+		if !retain {
+			continue
+		}
+
+		// the retained app is cleared on the broker
+		require.Equal(t, mqttMessage{Topic: "awtrix/custom/meetings", Retain: true}, nextMessage(t, published))
+	}
+
+	select {
+	case msg := <-published:
+		t.Fatalf("unexpected message: %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
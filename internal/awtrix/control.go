@@ -0,0 +1,173 @@
+package awtrix
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var (
+	_ Controller = (*AppControl)(nil)
+	_ Controller = (*DisplayControl)(nil)
+)
+
+const (
+	EndpointNotify   = "notify"
+	EndpointSwitch   = "switch"
+	EndpointSettings = "settings"
+	EndpointPower    = "power"
+)
+
+// Controller produces the device commands driven by the events
+type Controller interface {
+	// Commands returns the commands for the event, the device settings are read before changing them to be restored later
+	Commands(event ticker.Event, device SettingsReader) ([]ControlCommand, error)
+	// Restore returns the commands restoring the changed device settings on shutdown
+	Restore() ([]ControlCommand, error)
+}
+
+type AppControlConfig struct {
+	// App is the custom app name to switch to
+	App string
+	// Switch jumps to the app when the meeting begins
+	Switch bool
+	// Pin disables the apps auto transition during the meetings and enables it back afterwards
+	Pin bool
+}
+
+// DeviceSettings are the device settings changed by the controls, nil if unknown
+type DeviceSettings struct {
+	AutoTransition *bool `json:"ATRANS"`
+	AutoBrightness *bool `json:"ABRI"`
+	Brightness     *int  `json:"BRI"`
+}
+
+// SettingsReader returns the current device settings, the empty ones if the transport can't read them
+type SettingsReader func() (DeviceSettings, error)
+
+// ControlCommand is the payload to send to the awtrix API endpoint
+type ControlCommand struct {
+	Endpoint string
+	Payload  []byte
+	ack      func()
+}
+
+// Ack must be called after the command was delivered
+func (c ControlCommand) Ack() {
+	if c.ack != nil {
+		c.ack()
+	}
+}
+
+// AppControl keeps the custom app on the screen during the meetings
+type AppControl struct {
+	mu       sync.Mutex
+	cfg      AppControlConfig
+	switched bool
+	pinned   bool
+	// autoTransition is the device ATRANS before pinning, the awtrix default if unknown
+	autoTransition bool
+}
+
+func NewAppControl(cfg AppControlConfig) (*AppControl, error) {
+	if cfg.Switch && cfg.App == "" {
+		return nil, errors.New(".App is required to switch")
+	}
+
+	return &AppControl{
+		cfg:            cfg,
+		autoTransition: true,
+	}, nil
+}
+
+// Commands returns the commands to be sent for the event: switch to the app once the meeting begins,
+// pin it while the meeting is in progress and unpin afterwards
+func (c *AppControl) Commands(event ticker.Event, device SettingsReader) ([]ControlCommand, error) {
+	active := event.State.Base() == ticker.StateOnAir && !event.Snoozed
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []ControlCommand
+	if c.cfg.Pin && active != c.pinned {
+		if active {
+			settings, err := device()
+			if err != nil {
+				return nil, err
+			}
+
+			c.autoTransition = true
+			if settings.AutoTransition != nil {
+				c.autoTransition = *settings.AutoTransition
+			}
+		}
+
+		cmd, err := c.pin(active)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, cmd)
+	}
+
+	switch {
+	case !active:
+		c.switched = false
+	case c.cfg.Switch && !c.switched:
+		payload, err := json.Marshal(map[string]string{"name": c.cfg.App})
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, ControlCommand{
+			Endpoint: EndpointSwitch,
+			Payload:  payload,
+			ack: func() {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+
+				c.switched = true
+			},
+		})
+	}
+
+	return out, nil
+}
+
+// Restore returns the commands restoring the settings changed during the meeting
+func (c *AppControl) Restore() ([]ControlCommand, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.pinned {
+		return nil, nil
+	}
+
+	cmd, err := c.pin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return []ControlCommand{cmd}, nil
+}
+
+func (c *AppControl) pin(pinned bool) (ControlCommand, error) {
+	// ATRANS is the automatic switching to the next app, unpinning brings back the one seen before pinning
+	payload, err := json.Marshal(map[string]bool{"ATRANS": !pinned && c.autoTransition})
+	if err != nil {
+		return ControlCommand{}, err
+	}
+
+	return ControlCommand{
+		Endpoint: EndpointSettings,
+		Payload:  payload,
+		ack: func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			c.pinned = pinned
+		},
+	}, nil
+}
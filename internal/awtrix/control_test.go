@@ -0,0 +1,149 @@
+package awtrix
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+// deviceSettings returns the reader of the fixed device settings
+func deviceSettings(settings DeviceSettings) SettingsReader {
+	return func() (DeviceSettings, error) {
+		return settings, nil
+	}
+}
+
+func TestAppControl_Commands(t *testing.T) {
+	c, err := NewAppControl(AppControlConfig{
+		App:    "aweeting",
+		Switch: true,
+		Pin:    true,
+	})
+	require.NoError(t, err)
+
+	type command struct {
+		Endpoint string
+		Payload  string
+	}
+
+	steps := []struct {
+		name     string
+		event    ticker.Event
+		ack      bool
+		expected []command
+	}{
+		{
+			name:  "idle",
+			event: ticker.Event{State: ticker.StateIdle},
+			ack:   true,
+		},
+		{
+			name:  "on-air-undelivered",
+			event: ticker.Event{State: ticker.StateOnAir},
+			expected: []command{
+				{Endpoint: EndpointSettings, Payload: `{"ATRANS":false}`},
+				{Endpoint: EndpointSwitch, Payload: `{"name":"aweeting"}`},
+			},
+		},
+		{
+			name:  "on-air",
+			event: ticker.Event{State: ticker.StateOnAir},
+			ack:   true,
+			expected: []command{
+				{Endpoint: EndpointSettings, Payload: `{"ATRANS":false}`},
+				{Endpoint: EndpointSwitch, Payload: `{"name":"aweeting"}`},
+			},
+		},
+		{
+			name:  "wrapping-up",
+			event: ticker.Event{State: ticker.StateWrappingUp},
+			ack:   true,
+		},
+		{
+			name:  "snoozed",
+			event: ticker.Event{State: ticker.StateOnAir, Snoozed: true},
+			ack:   true,
+			expected: []command{
+				{Endpoint: EndpointSettings, Payload: `{"ATRANS":true}`},
+			},
+		},
+		{
+			name:  "idle-after",
+			event: ticker.Event{State: ticker.StateIdle},
+			ack:   true,
+		},
+	}
+
+	for _, st := range steps {
+		commands, err := c.Commands(st.event, deviceSettings(DeviceSettings{}))
+		require.NoError(t, err, st.name)
+
+		var actual []command
+		for _, cmd := range commands {
+			actual = append(actual, command{Endpoint: cmd.Endpoint, Payload: string(cmd.Payload)})
+			if st.ack {
+				cmd.Ack()
+			}
+		}
+
+		require.Equal(t, st.expected, actual, st.name)
+	}
+}
+
+func TestAppControl_Restore(t *testing.T) {
+	c, err := NewAppControl(AppControlConfig{Pin: true})
+	require.NoError(t, err)
+
+	restore, err := c.Restore()
+	require.NoError(t, err)
+	require.Empty(t, restore)
+
+	commands, err := c.Commands(ticker.Event{State: ticker.StateOnAir}, deviceSettings(DeviceSettings{}))
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	commands[0].Ack()
+
+	restore, err = c.Restore()
+	require.NoError(t, err)
+	require.Len(t, restore, 1)
+	require.Equal(t, EndpointSettings, restore[0].Endpoint)
+	require.JSONEq(t, `{"ATRANS":true}`, string(restore[0].Payload))
+}
+
+func TestAppControl_originalTransition(t *testing.T) {
+	c, err := NewAppControl(AppControlConfig{Pin: true})
+	require.NoError(t, err)
+
+	_, err = c.Commands(ticker.Event{State: ticker.StateOnAir}, func() (DeviceSettings, error) {
+		return DeviceSettings{}, errors.New("unreachable")
+	})
+	require.Error(t, err)
+
+	// the device had the auto transition off, so it stays off after the meeting
+	off := false
+	commands, err := c.Commands(ticker.Event{State: ticker.StateOnAir}, deviceSettings(DeviceSettings{AutoTransition: &off}))
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	require.JSONEq(t, `{"ATRANS":false}`, string(commands[0].Payload))
+	commands[0].Ack()
+
+	restore, err := c.Restore()
+	require.NoError(t, err)
+	require.Len(t, restore, 1)
+	require.JSONEq(t, `{"ATRANS":false}`, string(restore[0].Payload))
+	restore[0].Ack()
+
+	// the next meeting reads the settings again
+	on := true
+	commands, err = c.Commands(ticker.Event{State: ticker.StateOnAir}, deviceSettings(DeviceSettings{AutoTransition: &on}))
+	require.NoError(t, err)
+	commands[0].Ack()
+
+	commands, err = c.Commands(ticker.Event{State: ticker.StateIdle}, deviceSettings(DeviceSettings{}))
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	require.JSONEq(t, `{"ATRANS":true}`, string(commands[0].Payload))
+}
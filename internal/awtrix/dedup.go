@@ -0,0 +1,71 @@
+package awtrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Dedup tracks the last published payload per topic to skip the unchanged ones.
+// The payloads with the lifetime are refreshed after the half of it, so the app doesn't expire between the ticks.
+type Dedup struct {
+	mu     sync.Mutex
+	last   map[string]published
+	maxAge time.Duration
+}
+
+type published struct {
+	payload  []byte
+	at       time.Time
+	lifetime time.Duration
+}
+
+func NewDedup() *Dedup {
+	return &Dedup{
+		last: make(map[string]published),
+	}
+}
+
+// WithMaxAge resends the unchanged payloads once they are older than maxAge, regardless of the lifetime
+func (d *Dedup) WithMaxAge(maxAge time.Duration) *Dedup {
+	d.maxAge = maxAge
+	return d
+}
+
+// Skip reports whether the payload was already published to the topic and is still alive at the given moment
+func (d *Dedup) Skip(topic string, payload []byte, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	last, ok := d.last[topic]
+	if !ok || !bytes.Equal(last.payload, payload) {
+		return false
+	}
+
+	age := now.Sub(last.at)
+	if d.maxAge > 0 && age >= d.maxAge {
+		return false
+	}
+
+	return last.lifetime == 0 || age < last.lifetime/2
+}
+
+// Store remembers the payload published to the topic
+func (d *Dedup) Store(topic string, payload []byte, now time.Time) {
+	var app struct {
+		Lifetime int `json:"lifetime"`
+	}
+	if len(payload) > 0 {
+		_ = json.Unmarshal(payload, &app)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.last[topic] = published{
+		payload:  payload,
+		at:       now,
+		lifetime: time.Duration(app.Lifetime) * time.Second,
+	}
+}
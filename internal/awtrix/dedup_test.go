@@ -0,0 +1,88 @@
+package awtrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup_Skip(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	d := NewDedup()
+
+	require.False(t, d.Skip("app", []byte(`{"text":"1"}`), now))
+	d.Store("app", []byte(`{"text":"1"}`), now)
+	d.Store("lifetime", []byte(`{"text":"1","lifetime":600}`), now)
+	d.Store("empty", nil, now)
+
+	cases := []struct {
+		name     string
+		topic    string
+		payload  string
+		after    time.Duration
+		expected bool
+	}{
+		{
+			name:     "same",
+			topic:    "app",
+			payload:  `{"text":"1"}`,
+			after:    time.Hour,
+			expected: true,
+		},
+		{
+			name:    "changed",
+			topic:   "app",
+			payload: `{"text":"2"}`,
+		},
+		{
+			name:    "other-topic",
+			topic:   "other",
+			payload: `{"text":"1"}`,
+		},
+		{
+			name:     "lifetime-alive",
+			topic:    "lifetime",
+			payload:  `{"text":"1","lifetime":600}`,
+			after:    4 * time.Minute,
+			expected: true,
+		},
+		{
+			name:    "lifetime-refresh",
+			topic:   "lifetime",
+			payload: `{"text":"1","lifetime":600}`,
+			after:   5 * time.Minute,
+		},
+		{
+			name:     "empty",
+			topic:    "empty",
+			after:    time.Hour,
+			expected: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var payload []byte
+			if tc.payload != "" {
+				payload = []byte(tc.payload)
+			}
+
+			require.Equal(t, tc.expected, d.Skip(tc.topic, payload, now.Add(tc.after)))
+		})
+	}
+}
+
+func TestDedup_WithMaxAge(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	d := NewDedup().WithMaxAge(5 * time.Minute)
+
+	d.Store("app", []byte(`{"text":"1"}`), now)
+	d.Store("lifetime", []byte(`{"text":"1","lifetime":600}`), now)
+
+	require.True(t, d.Skip("app", []byte(`{"text":"1"}`), now.Add(4*time.Minute)))
+	require.False(t, d.Skip("app", []byte(`{"text":"1"}`), now.Add(5*time.Minute)))
+	// the lifetime refresh comes first if it's shorter
+	require.True(t, d.Skip("lifetime", []byte(`{"text":"1","lifetime":600}`), now.Add(4*time.Minute)))
+	require.False(t, d.Skip("lifetime", []byte(`{"text":"1","lifetime":600}`), now.Add(5*time.Minute)))
+}
@@ -0,0 +1,59 @@
+package awtrix
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DefaultOfflineAfter is the stats silence after which the device is considered offline, awtrix sends them every 10s by default
+const DefaultOfflineAfter = 1 * time.Minute
+
+// StatsTopic is the topic the device publishes its stats to periodically
+func StatsTopic(prefix string) string {
+	return prefix + "/stats"
+}
+
+type deviceStats struct {
+	Uptime int64 `json:"uptime"`
+}
+
+// DeviceWatcher detects the device coming back online by its stats: the first ones, the ones after the silence,
+// or the decreased uptime after the quick reboot
+type DeviceWatcher struct {
+	mu           sync.Mutex
+	offlineAfter time.Duration
+	seenAt       time.Time
+	uptime       int64
+}
+
+func NewDeviceWatcher(offlineAfter time.Duration) *DeviceWatcher {
+	if offlineAfter <= 0 {
+		offlineAfter = DefaultOfflineAfter
+	}
+
+	return &DeviceWatcher{
+		offlineAfter: offlineAfter,
+	}
+}
+
+// Online reports whether the stats received at the given moment mean the device has just (re)connected
+func (w *DeviceWatcher) Online(payload []byte, now time.Time) bool {
+	var stats deviceStats
+	// the uptime check is skipped for the unknown stats
+	known := json.Unmarshal(payload, &stats) == nil && stats.Uptime > 0
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	online := w.seenAt.IsZero() ||
+		now.Sub(w.seenAt) >= w.offlineAfter ||
+		known && stats.Uptime < w.uptime
+
+	w.seenAt = now
+	if known {
+		w.uptime = stats.Uptime
+	}
+
+	return online
+}
@@ -0,0 +1,53 @@
+package awtrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceWatcher_Online(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	w := NewDeviceWatcher(time.Minute)
+
+	steps := []struct {
+		name     string
+		after    time.Duration
+		payload  string
+		expected bool
+	}{
+		{
+			name:     "first",
+			payload:  `{"uptime": 100}`,
+			expected: true,
+		},
+		{
+			name:    "periodic",
+			after:   10 * time.Second,
+			payload: `{"uptime": 110}`,
+		},
+		{
+			name:     "rebooted",
+			after:    10 * time.Second,
+			payload:  `{"uptime": 3}`,
+			expected: true,
+		},
+		{
+			name:    "unknown",
+			after:   10 * time.Second,
+			payload: `garbage`,
+		},
+		{
+			name:     "silence",
+			after:    5 * time.Minute,
+			payload:  `{"uptime": 500}`,
+			expected: true,
+		},
+	}
+
+	for _, st := range steps {
+		now = now.Add(st.after)
+		require.Equal(t, st.expected, w.Online([]byte(st.payload), now), st.name)
+	}
+}
@@ -0,0 +1,183 @@
+package awtrix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+const DefaultWakeBefore = 15 * time.Minute
+
+type displayMode string
+
+const (
+	displayModeDay   displayMode = "day"
+	displayModeNight displayMode = "night"
+)
+
+type DisplayControlConfig struct {
+	// WorkingHours are the hours of the full brightness
+	WorkingHours ticker.WorkingHours
+	// WakeBefore is the offset before the meeting start the display is woken up at off hours
+	WakeBefore time.Duration
+	// Brightness during the working hours 1-255, the auto brightness is used if zero
+	Brightness int
+	// Dim is the brightness outside the working hours 1-255, disabled if zero
+	Dim int
+	// PowerOff turns the matrix off outside the working hours
+	PowerOff bool
+}
+
+// DisplayControl dims or powers off the display outside the working hours
+type DisplayControl struct {
+	mu      sync.Mutex
+	cfg     DisplayControlConfig
+	applied displayMode
+	// original are the device settings seen before the night mode
+	original DeviceSettings
+}
+
+func NewDisplayControl(cfg DisplayControlConfig) (*DisplayControl, error) {
+	if cfg.WorkingHours.IsZero() {
+		return nil, errors.New(".WorkingHours is required")
+	}
+
+	if cfg.Dim == 0 && !cfg.PowerOff {
+		return nil, errors.New(".Dim or .PowerOff is required")
+	}
+
+	if cfg.Dim < 0 || cfg.Dim > 255 {
+		return nil, fmt.Errorf("invalid dim brightness: %d", cfg.Dim)
+	}
+
+	if cfg.Brightness < 0 || cfg.Brightness > 255 {
+		return nil, fmt.Errorf("invalid brightness: %d", cfg.Brightness)
+	}
+
+	if cfg.WakeBefore <= 0 {
+		cfg.WakeBefore = DefaultWakeBefore
+	}
+
+	return &DisplayControl{
+		cfg: cfg,
+	}, nil
+}
+
+// Commands returns the commands switching the display to the night mode and back to the settings seen before it.
+// The day mode is left to the device until the night mode was applied.
+func (c *DisplayControl) Commands(event ticker.Event, device SettingsReader) ([]ControlCommand, error) {
+	mode := c.mode(event)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case mode == c.applied:
+		return nil, nil
+	case mode == displayModeDay && c.applied != displayModeNight:
+		return nil, nil
+	case mode == displayModeNight:
+		settings, err := device()
+		if err != nil {
+			return nil, err
+		}
+
+		c.original = settings
+	}
+
+	return c.commands(mode)
+}
+
+// Restore returns the commands switching the display back to the day settings
+func (c *DisplayControl) Restore() ([]ControlCommand, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.applied != displayModeNight {
+		return nil, nil
+	}
+
+	return c.commands(displayModeDay)
+}
+
+func (c *DisplayControl) mode(event ticker.Event) displayMode {
+	switch {
+	case event.State.Base() == ticker.StateOnAir, event.State.Base() == ticker.StateFocus:
+		return displayModeDay
+	case !event.IsZero() && event.Upcoming && event.ToStart <= c.cfg.WakeBefore:
+		return displayModeDay
+	case c.cfg.WorkingHours.Contains(event.Now):
+		return displayModeDay
+	default:
+		return displayModeNight
+	}
+}
+
+func (c *DisplayControl) commands(mode displayMode) ([]ControlCommand, error) {
+	type command struct {
+		endpoint string
+		payload  any
+	}
+
+	var cmds []command
+	switch {
+	case mode == displayModeNight && c.cfg.PowerOff:
+		cmds = append(cmds, command{EndpointPower, map[string]bool{"power": false}})
+	case mode == displayModeNight:
+		cmds = append(cmds, command{EndpointSettings, map[string]any{"ABRI": false, "BRI": c.cfg.Dim}})
+	default:
+		if c.cfg.PowerOff {
+			cmds = append(cmds, command{EndpointPower, map[string]bool{"power": true}})
+		}
+
+		switch {
+		case c.cfg.Brightness > 0:
+			cmds = append(cmds, command{EndpointSettings, map[string]any{"ABRI": false, "BRI": c.cfg.Brightness}})
+		case c.cfg.Dim > 0:
+			cmds = append(cmds, command{EndpointSettings, c.originalBrightness()})
+		}
+	}
+
+	out := make([]ControlCommand, len(cmds))
+	for i, cmd := range cmds {
+		payload, err := json.Marshal(cmd.payload)
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = ControlCommand{
+			Endpoint: cmd.endpoint,
+			Payload:  payload,
+		}
+	}
+
+	// the mode is applied once the last command is delivered
+	if len(out) > 0 {
+		out[len(out)-1].ack = func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			c.applied = mode
+		}
+	}
+
+	return out, nil
+}
+
+// originalBrightness returns the brightness settings seen before the night mode, the auto brightness if unknown
+func (c *DisplayControl) originalBrightness() map[string]any {
+	if c.original.AutoBrightness == nil {
+		return map[string]any{"ABRI": true}
+	}
+
+	out := map[string]any{"ABRI": *c.original.AutoBrightness}
+	if c.original.Brightness != nil {
+		out["BRI"] = *c.original.Brightness
+	}
+
+	return out
+}
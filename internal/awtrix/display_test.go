@@ -0,0 +1,152 @@
+package awtrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestDisplayControl_Commands(t *testing.T) {
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+
+	workingHours := ticker.WorkingHours{
+		From:     9 * time.Hour,
+		To:       18 * time.Hour,
+		Location: time.UTC,
+	}
+
+	type command struct {
+		Endpoint string
+		Payload  string
+	}
+
+	cases := []struct {
+		name     string
+		cfg      DisplayControlConfig
+		settings DeviceSettings
+		events   []ticker.Event
+		expected [][]command
+		restore  []command
+	}{
+		{
+			name: "dim",
+			cfg: DisplayControlConfig{
+				WorkingHours: workingHours,
+				Brightness:   200,
+				Dim:          10,
+			},
+			events: []ticker.Event{
+				{Now: at(12, 0), State: ticker.StateIdle},
+				{Now: at(12, 5), State: ticker.StateIdle},
+				{Now: at(22, 0), State: ticker.StateOffHours},
+				{Now: at(22, 30), State: ticker.StateOnAir, StartsAt: at(22, 30)},
+				{Now: at(23, 0), State: ticker.StateOffHours},
+			},
+			expected: [][]command{
+				nil,
+				nil,
+				{{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":10}`}},
+				{{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":200}`}},
+				{{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":10}`}},
+			},
+			restore: []command{
+				{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":200}`},
+			},
+		},
+		{
+			name: "dim-original",
+			cfg: DisplayControlConfig{
+				WorkingHours: workingHours,
+				Dim:          10,
+			},
+			settings: DeviceSettings{AutoBrightness: ptr(false), Brightness: ptr(120)},
+			events: []ticker.Event{
+				{Now: at(12, 0), State: ticker.StateIdle},
+				{Now: at(22, 0), State: ticker.StateOffHours},
+				{Now: at(22, 30), State: ticker.StateOnAir, StartsAt: at(22, 30)},
+				{Now: at(23, 0), State: ticker.StateOffHours},
+			},
+			expected: [][]command{
+				nil,
+				{{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":10}`}},
+				{{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":120}`}},
+				{{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":10}`}},
+			},
+			restore: []command{
+				{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":120}`},
+			},
+		},
+		{
+			name: "dim-unknown",
+			cfg: DisplayControlConfig{
+				WorkingHours: workingHours,
+				Dim:          10,
+			},
+			events: []ticker.Event{
+				{Now: at(12, 0), State: ticker.StateIdle},
+				{Now: at(22, 0), State: ticker.StateOffHours},
+			},
+			expected: [][]command{
+				nil,
+				{{Endpoint: EndpointSettings, Payload: `{"ABRI":false,"BRI":10}`}},
+			},
+			restore: []command{
+				{Endpoint: EndpointSettings, Payload: `{"ABRI":true}`},
+			},
+		},
+		{
+			name: "power-off",
+			cfg: DisplayControlConfig{
+				WorkingHours: workingHours,
+				PowerOff:     true,
+			},
+			events: []ticker.Event{
+				{Now: at(7, 0), State: ticker.StateUpcoming, Upcoming: true, StartsAt: at(9, 0), ToStart: 2 * time.Hour},
+				{Now: at(8, 50), State: ticker.StateUpcoming, Upcoming: true, StartsAt: at(9, 0), ToStart: 10 * time.Minute},
+				{Now: at(9, 0), State: ticker.StateOnAir, StartsAt: at(9, 0)},
+			},
+			expected: [][]command{
+				{{Endpoint: EndpointPower, Payload: `{"power":false}`}},
+				{{Endpoint: EndpointPower, Payload: `{"power":true}`}},
+				nil,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewDisplayControl(tc.cfg)
+			require.NoError(t, err)
+
+			toActual := func(commands []ControlCommand) []command {
+				var out []command
+				for _, cmd := range commands {
+					out = append(out, command{Endpoint: cmd.Endpoint, Payload: string(cmd.Payload)})
+					cmd.Ack()
+				}
+
+				return out
+			}
+
+			for i, event := range tc.events {
+				commands, err := c.Commands(event, deviceSettings(tc.settings))
+				require.NoError(t, err)
+				require.Equal(t, tc.expected[i], toActual(commands), "event %d", i)
+			}
+
+			restore, err := c.Restore()
+			require.NoError(t, err)
+			require.Equal(t, tc.restore, toActual(restore))
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
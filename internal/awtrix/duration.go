@@ -0,0 +1,110 @@
+package awtrix
+
+import (
+	"fmt"
+	"time"
+)
+
+type DurationStyle string
+
+const (
+	// DurationClock is HH:MM, "##:##" for a day and more
+	DurationClock DurationStyle = "clock"
+	// DurationCompact is like 2d3h, 1h5m or 45m
+	DurationCompact DurationStyle = "compact"
+	// DurationMinutes is the total minutes, like 125m
+	DurationMinutes DurationStyle = "minutes"
+	// DurationDays is the days only for a day and more (2d), compact otherwise
+	DurationDays DurationStyle = "days"
+)
+
+type DurationRounding string
+
+const (
+	RoundFloor   DurationRounding = "floor"
+	RoundCeil    DurationRounding = "ceil"
+	RoundNearest DurationRounding = "nearest"
+)
+
+// DurationFormat is the duration formatting style, clock with floor rounding by default
+type DurationFormat struct {
+	Style    DurationStyle
+	Rounding DurationRounding
+}
+
+func (f DurationFormat) Validate() error {
+	switch f.Style {
+	case "", DurationClock, DurationCompact, DurationMinutes, DurationDays:
+	default:
+		return fmt.Errorf("unsupported style: %q", f.Style)
+	}
+
+	switch f.Rounding {
+	case "", RoundFloor, RoundCeil, RoundNearest:
+	default:
+		return fmt.Errorf("unsupported rounding: %q", f.Rounding)
+	}
+
+	return nil
+}
+
+func (f DurationFormat) Format(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	d = f.round(d, time.Minute)
+	switch f.Style {
+	case DurationCompact:
+		return compactDuration(d, "")
+	case DurationMinutes:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case DurationDays:
+		if d < 24*time.Hour {
+			return compactDuration(d, "")
+		}
+
+		return fmt.Sprintf("%dd", int(f.round(d, 24*time.Hour)/(24*time.Hour)))
+	default:
+		if d >= 24*time.Hour {
+			return "##:##"
+		}
+
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d/time.Minute)%60)
+	}
+}
+
+func (f DurationFormat) round(d, unit time.Duration) time.Duration {
+	switch f.Rounding {
+	case RoundCeil:
+		if r := d.Truncate(unit); r != d {
+			return r + unit
+		}
+
+		return d
+	case RoundNearest:
+		return d.Round(unit)
+	default:
+		return d.Truncate(unit)
+	}
+}
+
+// compactDuration formats the duration by its two largest units joined with the separator, like 2d3h, 1h5m or 45m
+func compactDuration(d time.Duration, sep string) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+
+	switch {
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%dd%s%dh", days, sep, hours)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%dh%s%dm", hours, sep, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,117 @@
+package awtrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationFormat(t *testing.T) {
+	cases := []struct {
+		name     string
+		format   DurationFormat
+		in       time.Duration
+		expected string
+	}{
+		{
+			name:     "clock",
+			in:       65 * time.Minute,
+			expected: "01:05",
+		},
+		{
+			name:     "clock-floor",
+			in:       59 * time.Second,
+			expected: "00:00",
+		},
+		{
+			name:     "clock-ceil",
+			format:   DurationFormat{Rounding: RoundCeil},
+			in:       59 * time.Second,
+			expected: "00:01",
+		},
+		{
+			name:     "clock-nearest",
+			format:   DurationFormat{Rounding: RoundNearest},
+			in:       90*time.Second + time.Millisecond,
+			expected: "00:02",
+		},
+		{
+			name:     "clock-day",
+			in:       25 * time.Hour,
+			expected: "##:##",
+		},
+		{
+			name:     "compact",
+			format:   DurationFormat{Style: DurationCompact},
+			in:       65 * time.Minute,
+			expected: "1h5m",
+		},
+		{
+			name:     "compact-hours",
+			format:   DurationFormat{Style: DurationCompact},
+			in:       2 * time.Hour,
+			expected: "2h",
+		},
+		{
+			name:     "compact-days",
+			format:   DurationFormat{Style: DurationCompact},
+			in:       51*time.Hour + 10*time.Minute,
+			expected: "2d3h",
+		},
+		{
+			name:     "minutes",
+			format:   DurationFormat{Style: DurationMinutes},
+			in:       125 * time.Minute,
+			expected: "125m",
+		},
+		{
+			name:     "days",
+			format:   DurationFormat{Style: DurationDays},
+			in:       51 * time.Hour,
+			expected: "2d",
+		},
+		{
+			name:     "days-nearest",
+			format:   DurationFormat{Style: DurationDays, Rounding: RoundNearest},
+			in:       60 * time.Hour,
+			expected: "3d",
+		},
+		{
+			name:     "days-short",
+			format:   DurationFormat{Style: DurationDays},
+			in:       45 * time.Minute,
+			expected: "45m",
+		},
+		{
+			name:     "negative",
+			format:   DurationFormat{Style: DurationCompact},
+			in:       -time.Minute,
+			expected: "0m",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.format.Validate())
+			require.Equal(t, tc.expected, tc.format.Format(tc.in))
+		})
+	}
+
+	require.Error(t, DurationFormat{Style: "fancy"}.Validate())
+	require.Error(t, DurationFormat{Rounding: "up"}.Validate())
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		30 * time.Second:                 "<1m",
+		45 * time.Minute:                 "45m",
+		65 * time.Minute:                 "1h 5m",
+		2 * time.Hour:                    "2h",
+		-(51*time.Hour + 10*time.Minute): "2d 3h",
+	}
+
+	for in, expected := range cases {
+		require.Equal(t, expected, humanizeDuration(in), in.String())
+	}
+}
@@ -0,0 +1,330 @@
+package awtrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+type NotificationKind string
+
+const (
+	NotificationAlert   NotificationKind = "alert"
+	NotificationStarted NotificationKind = "started"
+	NotificationEnded   NotificationKind = "ended"
+	NotificationTitle   NotificationKind = "title"
+)
+
+type FormatterConfig struct {
+	SelfDestruct bool
+	// UpcomingLimit is the window of the upcoming progress bar
+	UpcomingLimit time.Duration
+	// Location of the clock times in the text templates, local by default
+	Location *time.Location
+	// Durations are the duration formats per state, the base state or DefaultDuration is used for the missing ones
+	Durations       map[ticker.State]DurationFormat
+	DefaultDuration DurationFormat
+	// Payloads per state, the base state payload is used for the missing ones.
+	// The payload text is the template, see ParseText
+	Payloads map[ticker.State]Payload
+	// Stages per state override the payload by the time left, the tightest matching one wins.
+	// The base state stages are used for the missing ones
+	Stages         map[ticker.State][]Stage
+	AlertPayload   Payload
+	StartedPayload Payload
+	EndedPayload   Payload
+	// TitleTicks is the number of the first on-air ticks showing the meeting title instead of the countdown
+	TitleTicks   int
+	TitlePayload Payload
+	// Redacted replaces the summary of the private meetings, DefaultRedacted if empty
+	Redacted string
+}
+
+type Formatter struct {
+	cfg           FormatterConfig
+	texts         map[ticker.State]*template.Template
+	stages        map[ticker.State][]stage
+	notifications map[NotificationKind]*template.Template
+	titleMu       sync.Mutex
+	titleInterval string
+	titleTicks    int
+}
+
+func NewFormatter(cfg FormatterConfig) (*Formatter, error) {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+
+	if cfg.Redacted == "" {
+		cfg.Redacted = DefaultRedacted
+	}
+
+	if err := cfg.DefaultDuration.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid default duration format: %w", err)
+	}
+
+	for state, format := range cfg.Durations {
+		if err := format.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s duration format: %w", state, err)
+		}
+	}
+
+	f := &Formatter{
+		cfg:           cfg,
+		texts:         make(map[ticker.State]*template.Template, len(cfg.Payloads)),
+		notifications: make(map[NotificationKind]*template.Template),
+		stages:        make(map[ticker.State][]stage, len(cfg.Stages)),
+	}
+
+	for state, stages := range cfg.Stages {
+		parsed, err := newStages(state, stages, cfg.Location, f.durationFormat(state))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s stages: %w", state, err)
+		}
+
+		f.stages[state] = parsed
+	}
+
+	for state, payload := range cfg.Payloads {
+		text := payload.Text
+		if text == "" {
+			text = defaultText(state)
+		}
+
+		tmpl, err := parseText(string(state), text, cfg.Location, f.durationFormat(state))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s text: %w", state, err)
+		}
+
+		f.texts[state] = tmpl
+	}
+
+	// alerts are about the upcoming meetings, the rest are about the meeting in progress
+	notifications := map[NotificationKind]struct {
+		text     string
+		fallback string
+		state    ticker.State
+	}{
+		NotificationAlert:   {cfg.AlertPayload.Text, DefaultAlertText, ticker.StateUpcoming},
+		NotificationStarted: {cfg.StartedPayload.Text, DefaultStartedText, ticker.StateOnAir},
+		NotificationEnded:   {cfg.EndedPayload.Text, DefaultEndedText, ticker.StateIdle},
+		NotificationTitle:   {cfg.TitlePayload.Text, DefaultTitleText, ticker.StateOnAir},
+	}
+	for kind, n := range notifications {
+		text := n.text
+		if text == "" {
+			text = n.fallback
+		}
+
+		tmpl, err := parseText(string(kind), text, cfg.Location, f.durationFormat(n.state))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s notification text: %w", kind, err)
+		}
+
+		f.notifications[kind] = tmpl
+	}
+
+	return f, nil
+}
+
+// Payload returns the custom app payload for the event, nil means "remove the app"
+func (f *Formatter) Payload(event ticker.Event) ([]byte, error) {
+	state := event.State
+	if _, ok := f.cfg.Payloads[state]; !ok {
+		state = state.Base()
+	}
+
+	if event.Snoozed || state == ticker.StateIdle && f.cfg.SelfDestruct {
+		return nil, nil
+	}
+
+	event = f.redact(event)
+	if payload, ok, err := f.titlePayload(event); err != nil || ok {
+		return payload, err
+	}
+
+	payload := f.cfg.Payloads[state]
+	tmpl := f.textTemplate(state)
+	if st, ok := f.stage(event); ok {
+		var err error
+		if payload, err = st.apply(payload); err != nil {
+			return nil, fmt.Errorf("apply stage: %w", err)
+		}
+
+		if st.text != nil {
+			tmpl = st.text
+		}
+	}
+
+	text, err := executeText(tmpl, event)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.Text = text
+	if payload.ProgressC != "" || payload.ProgressBC != "" {
+		payload.Progress = f.eventProgress(event)
+	}
+
+	return json.Marshal(payload)
+}
+
+// NotificationPayload returns the notify payload of the given kind
+func (f *Formatter) NotificationPayload(kind NotificationKind, event ticker.Event) ([]byte, error) {
+	var payload Payload
+	switch kind {
+	case NotificationAlert:
+		payload = f.cfg.AlertPayload
+	case NotificationStarted:
+		payload = f.cfg.StartedPayload
+	case NotificationEnded:
+		payload = f.cfg.EndedPayload
+	case NotificationTitle:
+		payload = f.cfg.TitlePayload
+	default:
+		return nil, fmt.Errorf("unsupported notification: %s", kind)
+	}
+
+	text, err := executeText(f.notifications[kind], f.redact(event))
+	if err != nil {
+		return nil, err
+	}
+
+	payload.Text = text
+	return json.Marshal(payload)
+}
+
+func (f *Formatter) textTemplate(state ticker.State) *template.Template {
+	if tmpl, ok := f.texts[state]; ok {
+		return tmpl
+	}
+
+	// no payload is configured for the state at all, the default text is always valid
+	tmpl, _ := parseText(string(state), defaultText(state), f.cfg.Location, f.durationFormat(state))
+	return tmpl
+}
+
+// stage returns the tightest stage matching the time left
+func (f *Formatter) stage(event ticker.Event) (stage, bool) {
+	left, ok := stageLeft(event)
+	if !ok {
+		return stage{}, false
+	}
+
+	stages, ok := f.stages[event.State]
+	if !ok {
+		stages = f.stages[event.State.Base()]
+	}
+
+	for _, s := range stages {
+		if left <= s.Below {
+			return s, true
+		}
+	}
+
+	return stage{}, false
+}
+
+// durationFormat returns the duration format of the state, falls back to the base state one and then to the default
+func (f *Formatter) durationFormat(state ticker.State) DurationFormat {
+	if format, ok := f.cfg.Durations[state]; ok {
+		return format
+	}
+
+	if format, ok := f.cfg.Durations[state.Base()]; ok {
+		return format
+	}
+
+	return f.cfg.DefaultDuration
+}
+
+// titlePayload returns the meeting title payload for the first on-air ticks of the interval
+func (f *Formatter) titlePayload(event ticker.Event) ([]byte, bool, error) {
+	if f.cfg.TitleTicks <= 0 || event.State.Base() != ticker.StateOnAir {
+		return nil, false, nil
+	}
+
+	f.titleMu.Lock()
+	defer f.titleMu.Unlock()
+
+	if id := event.IntervalID(); id != f.titleInterval {
+		f.titleInterval = id
+		f.titleTicks = 0
+	}
+
+	if f.titleTicks >= f.cfg.TitleTicks {
+		return nil, false, nil
+	}
+
+	text, err := executeText(f.notifications[NotificationTitle], event)
+	if err != nil || text == "" {
+		// fallback to the countdown if there is nothing to show
+		return nil, false, err
+	}
+
+	f.titleTicks++
+	payload := f.cfg.TitlePayload
+	payload.Text = text
+	out, err := json.Marshal(payload)
+	return out, true, err
+}
+
+// redact hides the details of the private meetings
+func (f *Formatter) redact(event ticker.Event) ticker.Event {
+	if event.Private {
+		event.Summary = f.cfg.Redacted
+		event.Location = ""
+	}
+
+	if event.Next.Private {
+		event.Next.Summary = f.cfg.Redacted
+		event.Next.Location = ""
+	}
+
+	return event
+}
+
+// defaultText returns the default text template of the state
+func defaultText(state ticker.State) string {
+	switch state.Base() {
+	case ticker.StateUpcoming:
+		return DefaultUpcomingText
+	case ticker.StateOnAir, ticker.StateFocus:
+		return DefaultOnAirText
+	default:
+		return DefaultIdleText
+	}
+}
+
+// eventProgress returns the elapsed percent of the meeting, or how close it is within the upcoming limit
+func (f *Formatter) eventProgress(event ticker.Event) int {
+	var elapsed, total time.Duration
+	switch event.State.Base() {
+	case ticker.StateUpcoming:
+		total = f.cfg.UpcomingLimit
+		elapsed = total - event.ToStart
+	case ticker.StateOnAir, ticker.StateFocus:
+		total = event.EndsAt.Sub(event.StartsAt)
+		elapsed = event.Now.Sub(event.StartsAt)
+	default:
+		return 0
+	}
+
+	if total <= 0 {
+		return 0
+	}
+
+	progress := int(100 * elapsed / total)
+	switch {
+	case progress < 0:
+		return 0
+	case progress > 100:
+		return 100
+	default:
+		return progress
+	}
+}
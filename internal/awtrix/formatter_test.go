@@ -0,0 +1,256 @@
+package awtrix
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestFormatter_progress(t *testing.T) {
+	start := time.Unix(544672800, 0)
+	interval := ticker.Interval{
+		Start: start,
+		End:   start.Add(time.Hour),
+	}
+
+	bar := Payload{ProgressC: "#00ff00"}
+	f, err := NewFormatter(FormatterConfig{
+		UpcomingLimit: time.Hour,
+		Payloads: map[ticker.State]Payload{
+			ticker.StateIdle:     bar,
+			ticker.StateUpcoming: bar,
+			ticker.StateOnAir:    bar,
+		},
+	})
+	require.NoError(t, err)
+
+	m := ticker.NewMachine(ticker.MachineConfig{
+		UpcomingLimit: time.Hour,
+	})
+
+	cases := []struct {
+		name     string
+		now      time.Time
+		expected int
+	}{
+		{
+			name:     "idle",
+			now:      start.Add(-2 * time.Hour),
+			expected: 0,
+		},
+		{
+			name:     "upcoming",
+			now:      start.Add(-45 * time.Minute),
+			expected: 25,
+		},
+		{
+			name:     "on-air",
+			now:      start.Add(15 * time.Minute),
+			expected: 25,
+		},
+		{
+			name:     "on-air-end",
+			now:      interval.End.Add(-time.Minute),
+			expected: 98,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, err := f.Payload(m.Next(interval.ToEvent(tc.now), tc.now))
+			require.NoError(t, err)
+
+			var payload Payload
+			require.NoError(t, json.Unmarshal(raw, &payload))
+			require.Equal(t, tc.expected, payload.Progress)
+			require.Equal(t, "#00ff00", payload.ProgressC)
+		})
+	}
+}
+
+func TestFormatter_text(t *testing.T) {
+	loc := time.FixedZone("UTC+7", 7*60*60)
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, loc)
+	event := ticker.Event{
+		Now:      start.Add(-90 * time.Minute),
+		State:    ticker.StateUpcoming,
+		Upcoming: true,
+		ToStart:  90 * time.Minute,
+		Left:     150 * time.Minute,
+		StartsAt: start,
+		EndsAt:   start.Add(time.Hour),
+		Summary:  "Planning",
+		Location: "Room 1",
+		Next: ticker.Interval{
+			Start:   start.Add(2 * time.Hour),
+			End:     start.Add(3 * time.Hour),
+			Summary: "Retro",
+		},
+	}
+
+	cases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "-01:30",
+		},
+		{
+			name:     "fields",
+			text:     "{{ .Summary }} @ {{ .Location }} in {{ humanize .ToStart }}",
+			expected: "Planning @ Room 1 in 1h 30m",
+		},
+		{
+			name:     "clock",
+			text:     "{{ clock .StartsAt }}-{{ clock .EndsAt }}",
+			expected: "10:00-11:00",
+		},
+		{
+			name:     "next",
+			text:     "{{ if not .Next.IsZero }}then {{ .Next.Summary }} at {{ clock .Next.Start }}{{ end }}",
+			expected: "then Retro at 12:00",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFormatter(FormatterConfig{
+				Location: loc,
+				Payloads: map[ticker.State]Payload{
+					ticker.StateUpcoming: {Text: tc.text},
+				},
+			})
+			require.NoError(t, err)
+
+			raw, err := f.Payload(event)
+			require.NoError(t, err)
+
+			var payload Payload
+			require.NoError(t, json.Unmarshal(raw, &payload))
+			require.Equal(t, tc.expected, payload.Text)
+		})
+	}
+}
+
+func TestFormatter_title(t *testing.T) {
+	start := time.Unix(544672800, 0)
+	interval := ticker.Interval{
+		Start:   start,
+		End:     start.Add(time.Hour),
+		Summary: "Interview",
+		Private: true,
+	}
+
+	f, err := NewFormatter(FormatterConfig{
+		TitleTicks: 2,
+		Redacted:   "Busy",
+	})
+	require.NoError(t, err)
+
+	m := ticker.NewMachine(ticker.MachineConfig{
+		UpcomingLimit: time.Hour,
+	})
+
+	var texts []string
+	for _, now := range []time.Time{start.Add(-time.Minute), start, start.Add(time.Minute), start.Add(2 * time.Minute)} {
+		raw, err := f.Payload(m.Next(interval.ToEvent(now), now))
+		require.NoError(t, err)
+
+		var payload Payload
+		require.NoError(t, json.Unmarshal(raw, &payload))
+		texts = append(texts, payload.Text)
+	}
+
+	require.Equal(t, []string{"-00:01", "Busy", "Busy", " 00:58"}, texts)
+}
+
+func TestFormatter_stages(t *testing.T) {
+	start := time.Unix(544672800, 0)
+	interval := ticker.Interval{
+		Start: start,
+		End:   start.Add(time.Hour),
+	}
+
+	f, err := NewFormatter(FormatterConfig{
+		Payloads: map[ticker.State]Payload{
+			ticker.StateUpcoming: {Color: "#ffffff", Icon: "11899"},
+			ticker.StateOnAir:    {Color: "#ff0000"},
+		},
+		Stages: map[ticker.State][]Stage{
+			ticker.StateUpcoming: {
+				{Below: 15 * time.Minute, Overrides: map[string]any{"color": "#ffff00"}},
+				{Below: time.Minute, Overrides: map[string]any{"color": "#ff0000", "blinkText": 300, "text": "NOW"}},
+				{Below: 5 * time.Minute, Overrides: map[string]any{"color": "#ff8800"}},
+			},
+			ticker.StateOnAir: {
+				{Below: 5 * time.Minute, Overrides: map[string]any{"color": "#ffbf00"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	m := ticker.NewMachine(ticker.MachineConfig{
+		UpcomingLimit: 2 * time.Hour,
+	})
+
+	cases := []struct {
+		name     string
+		now      time.Time
+		expected Payload
+	}{
+		{
+			name:     "far",
+			now:      start.Add(-time.Hour),
+			expected: Payload{Text: "-01:00", Color: "#ffffff", Icon: "11899"},
+		},
+		{
+			name:     "yellow",
+			now:      start.Add(-10 * time.Minute),
+			expected: Payload{Text: "-00:10", Color: "#ffff00", Icon: "11899"},
+		},
+		{
+			name:     "orange",
+			now:      start.Add(-5*time.Minute + time.Second),
+			expected: Payload{Text: "-00:04", Color: "#ff8800", Icon: "11899"},
+		},
+		{
+			name:     "blinking",
+			now:      start.Add(-30 * time.Second),
+			expected: Payload{Text: "NOW", Color: "#ff0000", Icon: "11899", BlinkText: 300},
+		},
+		{
+			name:     "on-air",
+			now:      start.Add(30 * time.Minute),
+			expected: Payload{Text: " 00:30", Color: "#ff0000"},
+		},
+		{
+			name:     "amber",
+			now:      interval.End.Add(-4 * time.Minute),
+			expected: Payload{Text: " 00:04", Color: "#ffbf00"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, err := f.Payload(m.Next(interval.ToEvent(tc.now), tc.now))
+			require.NoError(t, err)
+
+			var payload Payload
+			require.NoError(t, json.Unmarshal(raw, &payload))
+			require.Equal(t, tc.expected, payload)
+		})
+	}
+
+	_, err = NewFormatter(FormatterConfig{
+		Stages: map[ticker.State][]Stage{
+			ticker.StateUpcoming: {{Below: time.Minute, Overrides: map[string]any{"color": 42}}},
+		},
+	})
+	require.Error(t, err)
+}
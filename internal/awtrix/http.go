@@ -0,0 +1,165 @@
+package awtrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+const (
+	DefaultHttpTimeout = 10 * time.Second
+	DefaultHttpRetries = 3
+	// DefaultHttpResendAfter is the age of the unchanged app resent anyway, since w/o MQTT stats the device reboot goes unnoticed.
+	// It spans several ticks, so the dedup still skips the unchanged apps in between.
+	DefaultHttpResendAfter = 30 * time.Minute
+)
+
+type HttpUpdaterConfig struct {
+	// URL of the awtrix device, e.g. http://192.168.1.42
+	URL string
+	// App is the custom app name
+	App      string
+	Username string
+	Password string
+	Timeout  time.Duration
+	// Retries of the failed requests, negative disables
+	Retries int
+	// ResendAfter is the age of the unchanged app resent anyway, DefaultHttpResendAfter if zero, negative disables
+	ResendAfter time.Duration
+	Formatter   *Formatter
+	Notifier    *Notifier
+	// Controls drive the device settings by the events, optional
+	Controls []Controller
+}
+
+// HttpUpdater talks to the awtrix HTTP API directly, w/o MQTT broker
+type HttpUpdater struct {
+	httpc *resty.Client
+	cfg   HttpUpdaterConfig
+	delivery
+}
+
+func NewHttpUpdater(cfg HttpUpdaterConfig) (*HttpUpdater, error) {
+	if cfg.URL == "" {
+		return nil, errors.New(".URL is required")
+	}
+
+	if cfg.App == "" {
+		return nil, errors.New(".App is required")
+	}
+
+	if cfg.Formatter == nil {
+		return nil, errors.New(".Formatter is required")
+	}
+
+	timeout := DefaultHttpTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+
+	retries := DefaultHttpRetries
+	switch {
+	case cfg.Retries < 0:
+		retries = 0
+	case cfg.Retries > 0:
+		retries = cfg.Retries
+	}
+
+	resendAfter := DefaultHttpResendAfter
+	switch {
+	case cfg.ResendAfter < 0:
+		resendAfter = 0
+	case cfg.ResendAfter > 0:
+		resendAfter = cfg.ResendAfter
+	}
+
+	httpc := resty.New().
+		SetBaseURL(strings.TrimRight(cfg.URL, "/")).
+		SetTimeout(timeout).
+		SetHeader("Content-Type", "application/json").
+		// awtrix serves plain HTTP only, so don't warn about the basic auth over it
+		SetDisableWarn(true).
+		SetRetryCount(retries).
+		SetRetryWaitTime(100 * time.Millisecond).
+		SetRetryMaxWaitTime(5 * time.Second).
+		AddRetryCondition(func(rsp *resty.Response, err error) bool {
+			return err != nil || rsp.StatusCode() >= http.StatusInternalServerError
+		})
+
+	if cfg.Username != "" {
+		httpc.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+
+	u := &HttpUpdater{
+		httpc: httpc,
+		cfg:   cfg,
+	}
+	u.delivery = delivery{
+		formatter: cfg.Formatter,
+		notifier:  cfg.Notifier,
+		controls:  cfg.Controls,
+		dedup:     NewDedup().WithMaxAge(resendAfter),
+		app: func(ctx context.Context, payload []byte) error {
+			return u.post(ctx, "/api/custom", map[string]string{"name": cfg.App}, payload)
+		},
+		send: func(ctx context.Context, endpoint string, payload []byte) error {
+			return u.post(ctx, "/api/"+endpoint, nil, payload)
+		},
+		settings: u.settings,
+	}
+	return u, nil
+}
+
+func (u *HttpUpdater) Update(ctx context.Context, event ticker.Event) error {
+	return u.update(ctx, event)
+}
+
+func (u *HttpUpdater) Close(ctx context.Context) error {
+	return u.close(ctx)
+}
+
+// settings reads the current device settings
+func (u *HttpUpdater) settings(ctx context.Context) (DeviceSettings, error) {
+	var settings DeviceSettings
+	rsp, err := u.httpc.R().
+		SetContext(ctx).
+		SetResult(&settings).
+		Get("/api/settings")
+	if err != nil {
+		return DeviceSettings{}, fmt.Errorf("get /api/settings: %w", err)
+	}
+
+	if rsp.IsError() {
+		return DeviceSettings{}, fmt.Errorf("get /api/settings: non-2xx response: %s", rsp.Status())
+	}
+
+	return settings, nil
+}
+
+func (u *HttpUpdater) post(ctx context.Context, path string, query map[string]string, payload []byte) error {
+	req := u.httpc.R().
+		SetContext(ctx).
+		SetQueryParams(query)
+	// the empty body removes the custom app
+	if len(payload) > 0 {
+		req.SetBody(payload)
+	}
+
+	rsp, err := req.Post(path)
+	if err != nil {
+		return fmt.Errorf("post %s: %w", path, err)
+	}
+
+	if rsp.IsError() {
+		return fmt.Errorf("post %s: non-2xx response: %s", path, rsp.Status())
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package awtrix
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+type awtrixRequest struct {
+	Path string
+	App  string
+	Body string
+}
+
+// awtrixStub is the stand-in of the awtrix HTTP API failing the first failures requests
+type awtrixStub struct {
+	mu       sync.Mutex
+	failures int
+	requests []awtrixRequest
+	// settings are returned by GET /api/settings
+	settings string
+}
+
+func (s *awtrixStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pass" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodGet && r.URL.Path == "/api/settings" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(s.settings))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/custom", "/api/notify", "/api/settings":
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	s.requests = append(s.requests, awtrixRequest{
+		Path: r.URL.Path,
+		App:  r.URL.Query().Get("name"),
+		Body: string(body),
+	})
+	_, _ = w.Write([]byte("OK"))
+}
+
+func (s *awtrixStub) Requests() []awtrixRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := s.requests
+	s.requests = nil
+	return out
+}
+
+func TestHttpUpdater(t *testing.T) {
+	stub := &awtrixStub{failures: 2}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	formatter, err := NewFormatter(FormatterConfig{
+		SelfDestruct: true,
+		Payloads: map[ticker.State]Payload{
+			ticker.StateOnAir: {Color: "#FF0000"},
+		},
+		StartedPayload: Payload{Text: "started"},
+	})
+
+	require.NoError(t, err)
+
+	u, err := NewHttpUpdater(HttpUpdaterConfig{
+		URL:       srv.URL + "/",
+		App:       "meeting",
+		Username:  "user",
+		Password:  "pass",
+		Formatter: formatter,
+		Notifier: NewNotifier(NotifierConfig{
+			Formatter: formatter,
+			Started:   true,
+		}),
+	})
+	require.NoError(t, err)
+
+	start := time.Unix(544672800, 0)
+	interval := ticker.Interval{
+		Start: start,
+		End:   start.Add(time.Hour),
+	}
+	m := ticker.NewMachine(ticker.MachineConfig{
+		UpcomingLimit: time.Hour,
+	})
+
+	ctx := context.Background()
+	idle := interval.ToEvent(start.Add(-2 * time.Hour))
+	require.NoError(t, u.Update(ctx, m.Next(idle, idle.Now)))
+	require.Equal(t, []awtrixRequest{{Path: "/api/custom", App: "meeting"}}, stub.Requests())
+
+	require.NoError(t, u.Update(ctx, m.Next(interval.ToEvent(start), start)))
+	requests := stub.Requests()
+	require.Len(t, requests, 2)
+	require.Equal(t, "/api/custom", requests[0].Path)
+	require.Equal(t, "meeting", requests[0].App)
+	require.NotEmpty(t, requests[0].Body)
+	require.Equal(t, "/api/notify", requests[1].Path)
+	require.Contains(t, requests[1].Body, `"text":"started"`)
+
+	end := ticker.Interval{}.ToEvent(interval.End)
+	require.NoError(t, u.Update(ctx, m.Next(end, end.Now)))
+	require.Equal(t, []awtrixRequest{{Path: "/api/custom", App: "meeting"}}, stub.Requests())
+
+	unauthorized, err := NewHttpUpdater(HttpUpdaterConfig{
+		URL:       srv.URL,
+		App:       "meeting",
+		Formatter: formatter,
+	})
+	require.NoError(t, err)
+	require.Error(t, unauthorized.Update(ctx, end))
+
+	_, err = NewHttpUpdater(HttpUpdaterConfig{
+		URL:       srv.URL,
+		Formatter: formatter,
+	})
+	require.Error(t, err)
+}
+
+func TestHttpUpdater_settings(t *testing.T) {
+	stub := &awtrixStub{settings: `{"ATRANS":false,"ABRI":false,"BRI":120,"TEFF":1}`}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	formatter, err := NewFormatter(FormatterConfig{})
+	require.NoError(t, err)
+
+	control, err := NewAppControl(AppControlConfig{Pin: true})
+	require.NoError(t, err)
+
+	u, err := NewHttpUpdater(HttpUpdaterConfig{
+		URL:       srv.URL,
+		App:       "meeting",
+		Username:  "user",
+		Password:  "pass",
+		Formatter: formatter,
+		Controls:  []Controller{control},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Unix(544672800, 0)
+	require.NoError(t, u.Update(ctx, ticker.Event{Now: start, State: ticker.StateOnAir}))
+	require.Equal(t, awtrixRequest{Path: "/api/settings", Body: `{"ATRANS":false}`}, stub.Requests()[1])
+
+	// the auto transition was off before the meeting, so it's not turned on afterwards
+	require.NoError(t, u.Close(ctx))
+	require.Equal(t, []awtrixRequest{{Path: "/api/settings", Body: `{"ATRANS":false}`}}, stub.Requests())
+}
+
+func TestHttpUpdater_reboot(t *testing.T) {
+	stub := &awtrixStub{}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	formatter, err := NewFormatter(FormatterConfig{})
+	require.NoError(t, err)
+
+	u, err := NewHttpUpdater(HttpUpdaterConfig{
+		URL:       srv.URL,
+		App:       "meeting",
+		Username:  "user",
+		Password:  "pass",
+		Formatter: formatter,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Unix(544672800, 0)
+	idle := func(after time.Duration) ticker.Event {
+		return ticker.Event{Now: start.Add(after), State: ticker.StateIdle}
+	}
+
+	require.NoError(t, u.Update(ctx, idle(0)))
+	first := stub.Requests()
+	require.Len(t, first, 1)
+
+	// the unchanged app isn't resent on the next regular ticks
+	for after := ticker.DefaultTickInterval; after < DefaultHttpResendAfter; after += ticker.DefaultTickInterval {
+		require.NoError(t, u.Update(ctx, idle(after)))
+		require.Empty(t, stub.Requests(), after.String())
+	}
+
+	// the device rebooted meanwhile and lost the app, the HTTP transport has no stats to notice it,
+	// so the idle app is resent anyway after a while
+	require.NoError(t, u.Update(ctx, idle(DefaultHttpResendAfter)))
+	require.Equal(t, first, stub.Requests())
+}
+
+func TestHttpUpdater_resendAfter(t *testing.T) {
+	cases := []struct {
+		name        string
+		resendAfter time.Duration
+		resent      bool
+	}{
+		{
+			name:        "custom",
+			resendAfter: 10 * time.Minute,
+			resent:      true,
+		},
+		{
+			name:        "disabled",
+			resendAfter: -1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &awtrixStub{}
+			srv := httptest.NewServer(stub)
+			defer srv.Close()
+
+			formatter, err := NewFormatter(FormatterConfig{})
+			require.NoError(t, err)
+
+			u, err := NewHttpUpdater(HttpUpdaterConfig{
+				URL:         srv.URL,
+				App:         "meeting",
+				Username:    "user",
+				Password:    "pass",
+				ResendAfter: tc.resendAfter,
+				Formatter:   formatter,
+			})
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			start := time.Unix(544672800, 0)
+			idle := func(after time.Duration) ticker.Event {
+				return ticker.Event{Now: start.Add(after), State: ticker.StateIdle}
+			}
+
+			require.NoError(t, u.Update(ctx, idle(0)))
+			require.Len(t, stub.Requests(), 1)
+
+			require.NoError(t, u.Update(ctx, idle(ticker.DefaultTickInterval)))
+			require.Empty(t, stub.Requests())
+
+			require.NoError(t, u.Update(ctx, idle(10*time.Minute)))
+			require.Equal(t, tc.resent, len(stub.Requests()) == 1)
+		})
+	}
+}
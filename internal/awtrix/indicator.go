@@ -0,0 +1,112 @@
+package awtrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var _ Updater = (*IndicatorUpdater)(nil)
+
+// Indicator is the indicator LED payload
+type Indicator struct {
+	// The color (#hex), "0" turns the indicator off
+	Color string `json:"color"`
+	// Blinks the indicator in the given interval in ms
+	Blink int `json:"blink,omitempty"`
+	// Fades the indicator in the given interval in ms
+	Fade int `json:"fade,omitempty"`
+}
+
+// IndicatorOff turns the indicator off
+var IndicatorOff = Indicator{Color: "0"}
+
+type IndicatorUpdaterConfig struct {
+	Client *broker.Client
+	Prefix string
+	// Index of the indicator LED, 1-3
+	Index int
+	// Indicators per state, the base state one is used for the missing ones, the indicator is off if none
+	Indicators map[ticker.State]Indicator
+}
+
+// IndicatorUpdater lights the indicator LED, which is visible even if another app is on the screen
+type IndicatorUpdater struct {
+	mqtt       *broker.Client
+	topic      string
+	indicators map[ticker.State]Indicator
+	dedup      *Dedup
+}
+
+func NewIndicatorUpdater(cfg IndicatorUpdaterConfig) (*IndicatorUpdater, error) {
+	if cfg.Client == nil {
+		return nil, errors.New(".Client is required")
+	}
+
+	if cfg.Prefix == "" {
+		return nil, errors.New(".Prefix is required")
+	}
+
+	if cfg.Index < 1 || cfg.Index > 3 {
+		return nil, fmt.Errorf("invalid indicator: %d", cfg.Index)
+	}
+
+	return &IndicatorUpdater{
+		mqtt:       cfg.Client,
+		topic:      fmt.Sprintf("%s/indicator%d", cfg.Prefix, cfg.Index),
+		indicators: cfg.Indicators,
+		dedup:      NewDedup(),
+	}, nil
+}
+
+func (u *IndicatorUpdater) Update(ctx context.Context, event ticker.Event) error {
+	payload, err := json.Marshal(u.indicator(event))
+	if err != nil {
+		return fmt.Errorf("indicator marshal: %w", err)
+	}
+
+	if !event.Forced && u.dedup.Skip(u.topic, payload, event.Now) {
+		return nil
+	}
+
+	if err := u.publish(ctx, payload); err != nil {
+		return err
+	}
+
+	u.dedup.Store(u.topic, payload, event.Now)
+	return nil
+}
+
+// Close turns the indicator off
+func (u *IndicatorUpdater) Close(ctx context.Context) error {
+	payload, err := json.Marshal(IndicatorOff)
+	if err != nil {
+		return fmt.Errorf("indicator marshal: %w", err)
+	}
+
+	return u.publish(ctx, payload)
+}
+
+func (u *IndicatorUpdater) indicator(event ticker.Event) Indicator {
+	if event.Snoozed {
+		return IndicatorOff
+	}
+
+	if i, ok := u.indicators[event.State]; ok {
+		return i
+	}
+
+	if i, ok := u.indicators[event.State.Base()]; ok {
+		return i
+	}
+
+	return IndicatorOff
+}
+
+func (u *IndicatorUpdater) publish(ctx context.Context, payload []byte) error {
+	return u.mqtt.Publish(ctx, u.topic, false, payload)
+}
@@ -0,0 +1,58 @@
+package awtrix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestIndicatorUpdater_indicator(t *testing.T) {
+	onAir := Indicator{Color: "#ff0000"}
+	soon := Indicator{Color: "#ffff00", Blink: 500}
+	u := &IndicatorUpdater{
+		indicators: map[ticker.State]Indicator{
+			ticker.StateStartingSoon: soon,
+			ticker.StateOnAir:        onAir,
+		},
+	}
+
+	cases := []struct {
+		name     string
+		event    ticker.Event
+		expected Indicator
+	}{
+		{
+			name:     "idle",
+			event:    ticker.Event{State: ticker.StateIdle},
+			expected: IndicatorOff,
+		},
+		{
+			name:     "upcoming",
+			event:    ticker.Event{State: ticker.StateUpcoming},
+			expected: IndicatorOff,
+		},
+		{
+			name:     "starting-soon",
+			event:    ticker.Event{State: ticker.StateStartingSoon},
+			expected: soon,
+		},
+		{
+			name:     "wrapping-up",
+			event:    ticker.Event{State: ticker.StateWrappingUp},
+			expected: onAir,
+		},
+		{
+			name:     "snoozed",
+			event:    ticker.Event{State: ticker.StateOnAir, Snoozed: true},
+			expected: IndicatorOff,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, u.indicator(tc.event))
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package awtrix
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+type NotifierConfig struct {
+	Formatter *Formatter
+	// Alerter fires pre-meeting alerts, optional
+	Alerter *Alerter
+	// Started enables the notification on the transition to on-air
+	Started bool
+	// Ended enables the notification on the transition from on-air
+	Ended bool
+	// Title enables the meeting title notification on the transition to on-air
+	Title bool
+}
+
+type Notification struct {
+	Kind    NotificationKind
+	Payload []byte
+	ack     func() error
+}
+
+// Ack must be called after the notification was delivered
+func (n Notification) Ack() error {
+	if n.ack == nil {
+		return nil
+	}
+
+	return n.ack()
+}
+
+// Notifier decides which notifications must be published for the event
+type Notifier struct {
+	formatter *Formatter
+	alerter   *Alerter
+	started   bool
+	ended     bool
+	title     bool
+	mu        sync.Mutex
+	// notified is the base state of the last delivered transition, so the failed ones are retried
+	notified ticker.State
+}
+
+func NewNotifier(cfg NotifierConfig) *Notifier {
+	return &Notifier{
+		formatter: cfg.Formatter,
+		alerter:   cfg.Alerter,
+		started:   cfg.Started,
+		ended:     cfg.Ended,
+		title:     cfg.Title,
+	}
+}
+
+// Notifications returns the notifications to be sent for the event, snoozed events are silent
+func (n *Notifier) Notifications(event ticker.Event) ([]Notification, error) {
+	state := event.State.Base()
+	kind := n.transition(event)
+	if event.Snoozed {
+		n.setNotified(state)
+		return nil, nil
+	}
+
+	var out []Notification
+	add := func(kind NotificationKind, ack func() error) error {
+		payload, err := n.formatter.NotificationPayload(kind, event)
+		if err != nil {
+			return fmt.Errorf("%s payload marshal: %w", kind, err)
+		}
+
+		out = append(out, Notification{
+			Kind:    kind,
+			Payload: payload,
+			ack:     ack,
+		})
+		return nil
+	}
+
+	switch kind {
+	case NotificationStarted:
+		if n.started {
+			if err := add(NotificationStarted, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		if n.title && event.Summary != "" {
+			if err := add(NotificationTitle, nil); err != nil {
+				return nil, err
+			}
+		}
+	case NotificationEnded:
+		if n.ended {
+			if err := add(NotificationEnded, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(out) > 0 {
+		// the transition is delivered with its last notification
+		out[len(out)-1].ack = func() error {
+			n.setNotified(state)
+			return nil
+		}
+	} else {
+		n.setNotified(state)
+	}
+
+	if n.alerter != nil {
+		if alert, ok := n.alerter.Pending(event); ok {
+			ack := func() error {
+				return n.alerter.Ack(alert)
+			}
+
+			if err := add(NotificationAlert, ack); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// transition compares the event state with the last delivered one, or with the previous state on the first event
+func (n *Notifier) transition(event ticker.Event) NotificationKind {
+	n.mu.Lock()
+	prev := n.notified
+	n.mu.Unlock()
+
+	if prev == "" {
+		if !event.Changed() {
+			return ""
+		}
+
+		prev = event.PrevState.Base()
+	}
+
+	return transition(prev, event.State.Base())
+}
+
+func (n *Notifier) setNotified(state ticker.State) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.notified = state
+}
+
+func transition(prev, cur ticker.State) NotificationKind {
+	switch {
+	case cur == prev:
+		return ""
+	case cur == ticker.StateOnAir:
+		return NotificationStarted
+	case prev == ticker.StateOnAir:
+		return NotificationEnded
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,119 @@
+package awtrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestNotifier_transitions(t *testing.T) {
+	start := time.Unix(544672800, 0)
+	interval := ticker.Interval{
+		Start: start,
+		End:   start.Add(time.Hour),
+	}
+
+	formatter, err := NewFormatter(FormatterConfig{})
+	require.NoError(t, err)
+
+	n := NewNotifier(NotifierConfig{
+		Formatter: formatter,
+		Started:   true,
+		Ended:     true,
+	})
+	m := ticker.NewMachine(ticker.MachineConfig{
+		UpcomingLimit: time.Hour,
+		WrappingUp:    5 * time.Minute,
+	})
+
+	steps := []struct {
+		event    ticker.Event
+		expected []NotificationKind
+	}{
+		{
+			event: interval.ToEvent(start.Add(-10 * time.Minute)),
+		},
+		{
+			event:    interval.ToEvent(start),
+			expected: []NotificationKind{NotificationStarted},
+		},
+		{
+			event: interval.ToEvent(start.Add(10 * time.Minute)),
+		},
+		{
+			event: interval.ToEvent(interval.End.Add(-time.Minute)),
+		},
+		{
+			event:    ticker.Interval{}.ToEvent(interval.End),
+			expected: []NotificationKind{NotificationEnded},
+		},
+		{
+			event: ticker.Interval{}.ToEvent(interval.End.Add(time.Minute)),
+		},
+	}
+
+	for _, step := range steps {
+		notifications, err := n.Notifications(m.Next(step.event, step.event.Now))
+		require.NoError(t, err)
+
+		var actual []NotificationKind
+		for _, n := range notifications {
+			actual = append(actual, n.Kind)
+			require.NoError(t, n.Ack())
+		}
+		require.Equal(t, step.expected, actual, step.event.Now.String())
+	}
+}
+
+func TestNotifier_retry(t *testing.T) {
+	start := time.Unix(544672800, 0)
+	interval := ticker.Interval{
+		Start: start,
+		End:   start.Add(time.Hour),
+	}
+
+	formatter, err := NewFormatter(FormatterConfig{})
+	require.NoError(t, err)
+
+	n := NewNotifier(NotifierConfig{
+		Formatter: formatter,
+		Started:   true,
+		Title:     true,
+	})
+	m := ticker.NewMachine(ticker.MachineConfig{
+		UpcomingLimit: time.Hour,
+	})
+
+	kinds := func(now time.Time) []NotificationKind {
+		event := interval.ToEvent(now)
+		event.Summary = "Standup"
+		notifications, err := n.Notifications(m.Next(event, now))
+		require.NoError(t, err)
+
+		var out []NotificationKind
+		for _, n := range notifications {
+			out = append(out, n.Kind)
+		}
+		return out
+	}
+
+	require.Empty(t, kinds(start.Add(-time.Minute)))
+
+	// the delivery failed, so the notifications weren't acked
+	require.Equal(t, []NotificationKind{NotificationStarted, NotificationTitle}, kinds(start))
+	require.Equal(t, []NotificationKind{NotificationStarted, NotificationTitle}, kinds(start.Add(time.Minute)))
+
+	event := interval.ToEvent(start.Add(2 * time.Minute))
+	event.Summary = "Standup"
+	notifications, err := n.Notifications(m.Next(event, event.Now))
+	require.NoError(t, err)
+	require.Len(t, notifications, 2)
+	for _, n := range notifications {
+		require.NoError(t, n.Ack())
+	}
+
+	require.Empty(t, kinds(start.Add(3*time.Minute)))
+}
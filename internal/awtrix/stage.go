@@ -0,0 +1,141 @@
+package awtrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+// Stage overrides the payload fields while the time left is at or below the threshold:
+// the time to the start for the upcoming states, the time to the end for the on-air and focus ones
+type Stage struct {
+	Below time.Duration
+	// Overrides are the payload fields by their JSON names, the text one is the template
+	Overrides map[string]any
+}
+
+func (s Stage) Validate() error {
+	if s.Below <= 0 {
+		return fmt.Errorf("invalid threshold: %s", s.Below)
+	}
+
+	if err := s.validateOverrides(); err != nil {
+		return err
+	}
+
+	if text, ok := s.Overrides["text"]; ok {
+		t, ok := text.(string)
+		if !ok {
+			return fmt.Errorf("invalid text: %v", text)
+		}
+
+		if _, err := ParseText("stage", t); err != nil {
+			return fmt.Errorf("invalid text: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateOverrides checks that every override is the known payload field of the matching type
+func (s Stage) validateOverrides() error {
+	keys := make([]string, 0, len(s.Overrides))
+	for k := range s.Overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		raw, err := json.Marshal(map[string]any{k: s.Overrides[k]})
+		if err != nil {
+			return fmt.Errorf("invalid override %q: %w", k, err)
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&Payload{}); err != nil {
+			return fmt.Errorf("invalid override %q: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+// apply returns the payload with the fields overridden
+func (s Stage) apply(payload Payload) (Payload, error) {
+	if len(s.Overrides) == 0 {
+		return payload, nil
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return payload, err
+	}
+
+	fields := make(map[string]any)
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return payload, err
+	}
+
+	for k, v := range s.Overrides {
+		fields[k] = v
+	}
+
+	if raw, err = json.Marshal(fields); err != nil {
+		return payload, fmt.Errorf("invalid overrides: %w", err)
+	}
+
+	var out Payload
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return payload, fmt.Errorf("invalid overrides: %w", err)
+	}
+
+	return out, nil
+}
+
+type stage struct {
+	Stage
+	text *template.Template
+}
+
+// newStages returns the stages sorted from the tightest threshold
+func newStages(state ticker.State, in []Stage, loc *time.Location, durations DurationFormat) ([]stage, error) {
+	out := make([]stage, len(in))
+	for i, s := range in {
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+
+		out[i] = stage{Stage: s}
+		if text, ok := s.Overrides["text"].(string); ok {
+			tmpl, err := parseText(string(state), text, loc, durations)
+			if err != nil {
+				return nil, fmt.Errorf("stage %d: invalid text: %w", i, err)
+			}
+
+			out[i].text = tmpl
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Below < out[j].Below
+	})
+	return out, nil
+}
+
+// stageLeft returns the time left the stages of the state are selected by
+func stageLeft(event ticker.Event) (time.Duration, bool) {
+	switch event.State.Base() {
+	case ticker.StateUpcoming:
+		return event.ToStart, true
+	case ticker.StateOnAir, ticker.StateFocus:
+		return event.Left, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,54 @@
+package awtrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStage_Validate(t *testing.T) {
+	cases := []struct {
+		name      string
+		overrides map[string]any
+		err       string
+	}{
+		{
+			name: "valid",
+			overrides: map[string]any{
+				"color":   "#ff0000",
+				"text":    "{{ humanize .ToStart }}",
+				"rainbow": true,
+			},
+		},
+		{
+			name:      "unknown",
+			overrides: map[string]any{"colour": "#ff0000"},
+			err:       `invalid override "colour"`,
+		},
+		{
+			name:      "type",
+			overrides: map[string]any{"rainbow": "yes"},
+			err:       `invalid override "rainbow"`,
+		},
+		{
+			name:      "text",
+			overrides: map[string]any{"text": "{{ .Summary"},
+			err:       "invalid text",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Stage{Below: time.Minute, Overrides: tc.overrides}.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	require.Error(t, Stage{}.Validate())
+}
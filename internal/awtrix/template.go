@@ -0,0 +1,82 @@
+package awtrix
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+// Default texts of the messages w/o the configured one
+const (
+	DefaultIdleText     = " ##:##"
+	DefaultUpcomingText = "-{{ formatDuration .ToStart }}"
+	DefaultOnAirText    = " {{ formatDuration .Left }}"
+	DefaultAlertText    = "-{{ formatDuration .ToStart }}"
+	DefaultStartedText  = "ON AIR"
+	DefaultEndedText    = "FREE"
+	DefaultTitleText    = "{{ .Summary }}"
+	// DefaultRedacted replaces the summary of the private meetings
+	DefaultRedacted = "Private meeting"
+)
+
+// ParseText parses the message text template, the template data is ticker.Event.
+// Besides the syntax, the template is checked against the zero event to catch the unknown fields early.
+func ParseText(name, text string) (*template.Template, error) {
+	return parseText(name, text, time.Local, DurationFormat{})
+}
+
+func parseText(name, text string, loc *time.Location, durations DurationFormat) (*template.Template, error) {
+	tmpl, err := template.New(name).
+		Funcs(templateFuncs(loc, durations)).
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tmpl.Execute(io.Discard, ticker.Event{}); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+func templateFuncs(loc *time.Location, durations DurationFormat) template.FuncMap {
+	return template.FuncMap{
+		"formatDuration": durations.Format,
+		"humanize":       humanizeDuration,
+		"clock": func(t time.Time) string {
+			if t.IsZero() {
+				return "--:--"
+			}
+
+			return t.In(loc).Format("15:04")
+		},
+	}
+}
+
+func executeText(tmpl *template.Template, event ticker.Event) (string, error) {
+	var out strings.Builder
+	if err := tmpl.Execute(&out, event); err != nil {
+		return "", fmt.Errorf("execute %q text template: %w", tmpl.Name(), err)
+	}
+
+	return out.String(), nil
+}
+
+// humanizeDuration formats the duration as the short human-readable one, e.g. "1h 5m"
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+
+	d = d.Truncate(time.Minute)
+	if d == 0 {
+		return "<1m"
+	}
+
+	return compactDuration(d, " ")
+}
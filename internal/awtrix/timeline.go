@@ -0,0 +1,216 @@
+package awtrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var _ Updater = (*TimelineUpdater)(nil)
+
+const (
+	timelineWidth  = 32
+	timelineHeight = 8
+	// the busy blocks track rows
+	timelineTop    = 2
+	timelineBottom = 5
+)
+
+// DrawCommand is the awtrix drawing instruction, e.g. {"df": [x, y, w, h, "#color"]}
+type DrawCommand map[string][]any
+
+// TimelineColors are the timeline colors (#hex), the empty color is not drawn
+type TimelineColors struct {
+	// Free is the track background
+	Free string
+	// Elapsed is the part of the track before now
+	Elapsed string
+	// Meeting and Focus are the busy blocks per class
+	Meeting string
+	Focus   string
+	// Current is the block of the current interval
+	Current string
+	// Now is the now marker
+	Now string
+	// Hours are the hour ticks under the track
+	Hours string
+}
+
+type TimelineUpdaterConfig struct {
+	Client *broker.Client
+	Topic  string
+	// Location of the day boundaries
+	Location *time.Location
+	// From and To are the shown range offsets from the midnight, e.g. 9h and 19h
+	From time.Duration
+	To   time.Duration
+	// Duration of the app in the loop, the awtrix default is used if zero
+	Duration int
+	Colors   TimelineColors
+	// Retain the app messages, so the device gets them back after reboot
+	Retain bool
+}
+
+// TimelineUpdater draws the day timeline with the busy blocks and the now marker in a separate custom app
+type TimelineUpdater struct {
+	mqtt     *broker.Client
+	topic    string
+	loc      *time.Location
+	from     time.Duration
+	to       time.Duration
+	duration int
+	colors   TimelineColors
+	retain   bool
+	dedup    *Dedup
+}
+
+type timelinePayload struct {
+	Draw     []DrawCommand `json:"draw"`
+	Duration int           `json:"duration,omitempty"`
+}
+
+func NewTimelineUpdater(cfg TimelineUpdaterConfig) (*TimelineUpdater, error) {
+	if cfg.Client == nil {
+		return nil, errors.New(".Client is required")
+	}
+
+	if cfg.Topic == "" {
+		return nil, errors.New(".Topic is required")
+	}
+
+	if cfg.From < 0 || cfg.To > 24*time.Hour || cfg.From >= cfg.To {
+		return nil, fmt.Errorf("invalid range: %s -> %s", cfg.From, cfg.To)
+	}
+
+	loc := cfg.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return &TimelineUpdater{
+		mqtt:     cfg.Client,
+		topic:    cfg.Topic,
+		loc:      loc,
+		from:     cfg.From,
+		to:       cfg.To,
+		duration: cfg.Duration,
+		colors:   cfg.Colors,
+		retain:   cfg.Retain,
+		dedup:    NewDedup(),
+	}, nil
+}
+
+// Update draws the timeline, the app is removed outside the shown range
+func (u *TimelineUpdater) Update(ctx context.Context, event ticker.Event) error {
+	var payload []byte
+	if draw := u.Draw(event); draw != nil {
+		var err error
+		payload, err = json.Marshal(timelinePayload{
+			Draw:     draw,
+			Duration: u.duration,
+		})
+		if err != nil {
+			return fmt.Errorf("timeline marshal: %w", err)
+		}
+	}
+
+	if !event.Forced && u.dedup.Skip(u.topic, payload, event.Now) {
+		return nil
+	}
+
+	if err := u.publish(ctx, payload); err != nil {
+		return err
+	}
+
+	u.dedup.Store(u.topic, payload, event.Now)
+	return nil
+}
+
+// Close removes the app
+func (u *TimelineUpdater) Close(ctx context.Context) error {
+	return u.publish(ctx, nil)
+}
+
+// Draw returns the drawing instructions of the event day, nil if the event is out of the shown range
+func (u *TimelineUpdater) Draw(event ticker.Event) []DrawCommand {
+	now := event.Now.In(u.loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, u.loc)
+	start, end := midnight.Add(u.from), midnight.Add(u.to)
+	if now.Before(start) || !now.Before(end) {
+		return nil
+	}
+
+	span := end.Sub(start)
+	px := func(t time.Time) int {
+		switch {
+		case !t.After(start):
+			return 0
+		case !t.Before(end):
+			return timelineWidth
+		default:
+			return int(t.Sub(start) * timelineWidth / span)
+		}
+	}
+
+	var out []DrawCommand
+	fill := func(x0, x1 int, color string) {
+		if color == "" || x1 <= x0 {
+			return
+		}
+
+		out = append(out, DrawCommand{
+			"df": {x0, timelineTop, x1 - x0, timelineBottom - timelineTop + 1, color},
+		})
+	}
+
+	x := px(now)
+	fill(0, timelineWidth, u.colors.Free)
+	fill(0, x, u.colors.Elapsed)
+	for _, i := range event.Intervals {
+		if !i.Start.Before(end) || !i.End.After(start) {
+			continue
+		}
+
+		color := u.colors.Meeting
+		if i.Class == ticker.ClassFocus {
+			color = u.colors.Focus
+		}
+
+		if i.Start.Equal(event.StartsAt) && !event.Upcoming {
+			color = u.colors.Current
+		}
+
+		// short blocks must be visible anyway
+		x0, x1 := px(i.Start), px(i.End)
+		if x1 == x0 {
+			x1++
+		}
+
+		fill(x0, x1, color)
+	}
+
+	if u.colors.Hours != "" {
+		for off := u.from.Truncate(time.Hour) + time.Hour; off < u.to; off += time.Hour {
+			out = append(out, DrawCommand{
+				"dp": {px(midnight.Add(off)), timelineHeight - 1, u.colors.Hours},
+			})
+		}
+	}
+
+	if u.colors.Now != "" {
+		out = append(out, DrawCommand{
+			"dl": {x, timelineTop - 1, x, timelineBottom + 1, u.colors.Now},
+		})
+	}
+
+	return out
+}
+
+func (u *TimelineUpdater) publish(ctx context.Context, payload []byte) error {
+	return u.mqtt.Publish(ctx, u.topic, u.retain, payload)
+}
@@ -0,0 +1,64 @@
+package awtrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestTimelineUpdater_Draw(t *testing.T) {
+	u := &TimelineUpdater{
+		loc:  time.UTC,
+		from: 9 * time.Hour,
+		to:   17 * time.Hour,
+		colors: TimelineColors{
+			Free:    "free",
+			Elapsed: "elapsed",
+			Meeting: "meeting",
+			Focus:   "focus",
+			Current: "current",
+			Now:     "now",
+			Hours:   "hours",
+		},
+	}
+
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+
+	event := ticker.Event{
+		Now:      at(11, 0),
+		StartsAt: at(10, 30),
+		EndsAt:   at(11, 30),
+		Intervals: []ticker.Interval{
+			{Start: at(10, 30), End: at(11, 30)},
+			{Start: at(13, 0), End: at(14, 0), Class: ticker.ClassFocus},
+			{Start: at(15, 0), End: at(15, 5)},
+			{Start: at(18, 0), End: at(19, 0)},
+		},
+	}
+
+	expected := []DrawCommand{
+		{"df": {0, 2, 32, 4, "free"}},
+		{"df": {0, 2, 8, 4, "elapsed"}},
+		{"df": {6, 2, 4, 4, "current"}},
+		{"df": {16, 2, 4, 4, "focus"}},
+		{"df": {24, 2, 1, 4, "meeting"}},
+	}
+	for x := 4; x < 32; x += 4 {
+		expected = append(expected, DrawCommand{"dp": {x, 7, "hours"}})
+	}
+	expected = append(expected, DrawCommand{"dl": {8, 1, 8, 6, "now"}})
+
+	require.Equal(t, expected, u.Draw(event))
+
+	event.Now = at(8, 59)
+	require.Nil(t, u.Draw(event))
+
+	event.Now = at(17, 0)
+	require.Nil(t, u.Draw(event))
+}
@@ -15,14 +15,30 @@ type Payload struct {
 	Background string `json:"background,omitempty"`
 	// Fades each letter in the text differently through the entire RGB spectrum
 	Rainbow bool `json:"rainbow,omitempty"`
+	// Blinks the text in an given interval in ms, not compatible with gradient or rainbow
+	BlinkText int `json:"blinkText,omitempty"`
 	// The icon ID or filename (without extension) to display on the app
 	Icon string `json:"icon,omitempty"`
 	// 0 = Icon doesn't move. 1 = Icon moves with text and will not appear again. 2 = Icon moves with text but appears again when the text starts to scroll again.
 	PushIcon int `json:"pushIcon,omitempty"`
+	// Shows a progress bar, value can be 0-100. Filled by the meeting progress if the progress color is set
+	Progress int `json:"progress,omitempty"`
+	// The color of the progress bar (#hex)
+	ProgressC string `json:"progressC,omitempty"`
+	// The color of the progress bar background (#hex)
+	ProgressBC string `json:"progressBC,omitempty"`
 	// Sets how many times the text should be scrolled through the matrix before the app ends
 	Repeat int `json:"repeat"`
 	// Sets how long the app or notification should be displayed
 	Duration int `json:"duration"`
+	// Set it to true, to hold your **notification** on top until you press the middle button or dismiss it via HomeAssistant. This key only belongs to notification
+	Hold bool `json:"hold,omitempty"`
+	// The filename of your RTTTL ringtone file placed in the MELODIES folder (without extension)
+	Sound string `json:"sound,omitempty"`
+	// Allows to send the RTTTL sound string with the json
+	Rtttl string `json:"rtttl,omitempty"`
+	// Loops the sound or rtttl as long as the notification is running
+	LoopSound bool `json:"loopSound,omitempty"`
 	// Enables or disables autoscaling for bar and linechart
 	Autoscale bool `json:"autoscale"`
 	//  Defines the position of your custompage in the loop, starting at 0 for the first position. This will only apply with your first push. This function is experimental
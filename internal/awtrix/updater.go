@@ -0,0 +1,137 @@
+package awtrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var (
+	_ Updater = (*MqttUpdater)(nil)
+	_ Updater = (*HttpUpdater)(nil)
+)
+
+// dedupApp is the dedup key of the custom app payload
+const dedupApp = "app"
+
+// Updater delivers the ticker events to the awtrix device
+type Updater interface {
+	Update(ctx context.Context, event ticker.Event) error
+	Close(ctx context.Context) error
+}
+
+// delivery formats the events and sends them over the updater transport
+type delivery struct {
+	formatter *Formatter
+	notifier  *Notifier
+	controls  []Controller
+	dedup     *Dedup
+	// app sends the custom app payload, the empty payload removes the app
+	app func(ctx context.Context, payload []byte) error
+	// send sends the payload to the awtrix API endpoint, e.g. notify
+	send func(ctx context.Context, endpoint string, payload []byte) error
+	// settings reads the current device settings, optional
+	settings func(ctx context.Context) (DeviceSettings, error)
+}
+
+// update delivers the custom app payload, the control commands and the pending notifications
+func (d *delivery) update(ctx context.Context, event ticker.Event) error {
+	payload, err := d.formatter.Payload(event)
+	if err != nil {
+		return fmt.Errorf("payload marshal: %w", err)
+	}
+
+	if event.Forced || !d.dedup.Skip(dedupApp, payload, event.Now) {
+		if err := d.app(ctx, payload); err != nil {
+			return err
+		}
+
+		d.dedup.Store(dedupApp, payload, event.Now)
+	}
+
+	device := d.settingsReader(ctx)
+	for _, c := range d.controls {
+		commands, err := c.Commands(event, device)
+		if err != nil {
+			return fmt.Errorf("control: %w", err)
+		}
+
+		if err := d.sendCommands(ctx, commands); err != nil {
+			return err
+		}
+	}
+
+	if d.notifier == nil {
+		return nil
+	}
+
+	notifications, err := d.notifier.Notifications(event)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notifications {
+		if err := d.send(ctx, EndpointNotify, n.Payload); err != nil {
+			return fmt.Errorf("send %s notification: %w", n.Kind, err)
+		}
+
+		if err := n.Ack(); err != nil {
+			return fmt.Errorf("ack %s notification: %w", n.Kind, err)
+		}
+	}
+
+	return nil
+}
+
+// settingsReader returns the reader fetching the device settings at most once per update
+func (d *delivery) settingsReader(ctx context.Context) SettingsReader {
+	var settings *DeviceSettings
+	return func() (DeviceSettings, error) {
+		if d.settings == nil {
+			return DeviceSettings{}, nil
+		}
+
+		if settings == nil {
+			s, err := d.settings(ctx)
+			if err != nil {
+				return DeviceSettings{}, fmt.Errorf("read settings: %w", err)
+			}
+
+			settings = &s
+		}
+
+		return *settings, nil
+	}
+}
+
+// close restores the device settings changed by the controls
+func (d *delivery) close(ctx context.Context) error {
+	var errs []error
+	for _, c := range d.controls {
+		commands, err := c.Restore()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("control: %w", err))
+			continue
+		}
+
+		if err := d.sendCommands(ctx, commands); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *delivery) sendCommands(ctx context.Context, commands []ControlCommand) error {
+	for _, cmd := range commands {
+		if err := d.send(ctx, cmd.Endpoint, cmd.Payload); err != nil {
+			return fmt.Errorf("send %s: %w", cmd.Endpoint, err)
+		}
+
+		cmd.Ack()
+	}
+
+	return nil
+}
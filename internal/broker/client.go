@@ -0,0 +1,302 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ConnectionTimeout = 5 * time.Minute
+	DisconnectQuiesce = 250
+	DefaultClientID   = "aweeting"
+	// StatusTimeout bounds the status publish, so the close doesn't hang on the dead broker
+	StatusTimeout = 5 * time.Second
+)
+
+type Config struct {
+	// Upstream is the broker URL, e.g. tcp://mqtt.lan:1883, ssl://mqtt.lan:8883 or wss://mqtt.lan/mqtt
+	Upstream  string
+	Username  string
+	Password  string
+	TLS       TLSConfig
+	Websocket WebsocketConfig
+	// ClientID must be unique per broker, DefaultClientID is used if empty
+	ClientID string
+	// RandomSuffix appends the random suffix to the client ID, so the instances don't kick each other off
+	RandomSuffix bool
+	// QoS of the published messages, 0-2
+	QoS          byte
+	CleanSession bool
+	// KeepAlive, ConnectTimeout and WriteTimeout fall back to the client defaults if zero
+	KeepAlive      time.Duration
+	ConnectTimeout time.Duration
+	WriteTimeout   time.Duration
+	// Will is published by the broker if the client disconnects uncleanly, disabled if the topic is empty
+	Will Will
+	// Status topic gets the retained StatusOnline on connect and StatusOffline on close, optional.
+	// The will is expected to publish StatusOffline there as well.
+	Status string
+}
+
+// Will is the last will message
+type Will struct {
+	Topic   string
+	Payload string
+	Retain  bool
+}
+
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+// MessageHandler is called with the payload of the message received on the subscribed topic
+type MessageHandler func(payload []byte)
+
+// Client is the MQTT connection shared between all the components
+type Client struct {
+	mqtt   mqtt.Client
+	qos    byte
+	status string
+	mu     sync.Mutex
+	subs   map[string]MessageHandler
+	// connected is set after the first connection, so the next ones are the reconnects
+	connected   bool
+	onReconnect func()
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Upstream == "" {
+		return nil, errors.New(".Upstream is required")
+	}
+
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS: %d", cfg.QoS)
+	}
+
+	l := log.With().Str("name", "mqtt").Logger()
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(cfg.Upstream)
+	if cfg.Username != "" {
+		opts.SetUsername(cfg.Username)
+		opts.SetPassword(cfg.Password)
+	}
+
+	if !cfg.TLS.IsZero() || isSecure(cfg.Upstream) {
+		tlsCfg, err := cfg.TLS.TLS()
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS config: %w", err)
+		}
+
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	cfg.Websocket.apply(opts)
+
+	clientID, err := cfg.clientID()
+	if err != nil {
+		return nil, fmt.Errorf("generate client ID: %w", err)
+	}
+
+	opts.SetClientID(clientID)
+	opts.SetCleanSession(cfg.CleanSession)
+	if cfg.KeepAlive > 0 {
+		opts.SetKeepAlive(cfg.KeepAlive)
+	}
+
+	if cfg.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(cfg.ConnectTimeout)
+	}
+
+	if cfg.WriteTimeout > 0 {
+		opts.SetWriteTimeout(cfg.WriteTimeout)
+	}
+
+	if cfg.Will.Topic != "" {
+		opts.SetWill(cfg.Will.Topic, cfg.Will.Payload, cfg.QoS, cfg.Will.Retain)
+	}
+
+	c := &Client{
+		subs:   make(map[string]MessageHandler),
+		qos:    cfg.QoS,
+		status: cfg.Status,
+	}
+
+	l = l.With().Str("client_id", clientID).Logger()
+	opts.SetAutoReconnect(true)
+	// handlers may publish or (un)subscribe, so they must not block the incoming messages router
+	opts.SetOrderMatters(false)
+	opts.OnConnect = func(_ mqtt.Client) {
+		l.Info().Msg("connected")
+		c.online(l)
+		c.resubscribe()
+		c.reconnected()
+	}
+	opts.OnConnectionLost = func(_ mqtt.Client, err error) {
+		l.Warn().Err(err).Msg("disconnected")
+	}
+	opts.OnReconnecting = func(_ mqtt.Client, _ *mqtt.ClientOptions) {
+		l.Info().Msg("reconnecting")
+	}
+
+	c.mqtt = mqtt.NewClient(opts)
+	if token := c.mqtt.Connect(); token.WaitTimeout(ConnectionTimeout) && token.Error() != nil {
+		return nil, fmt.Errorf("MQTT connection failed: %w", token.Error())
+	}
+
+	return c, nil
+}
+
+// Status returns the status topic, empty if disabled
+func (c *Client) Status() string {
+	return c.status
+}
+
+func (c *Client) Publish(ctx context.Context, topic string, retained bool, payload []byte) error {
+	return wait(ctx, c.mqtt.Publish(topic, c.qos, retained, payload))
+}
+
+// Subscribe subscribes to the topic, the subscription is restored on reconnect
+func (c *Client) Subscribe(ctx context.Context, topic string, handler MessageHandler) error {
+	c.mu.Lock()
+	c.subs[topic] = handler
+	c.mu.Unlock()
+
+	return wait(ctx, c.subscribe(topic, handler))
+}
+
+func (c *Client) Unsubscribe(ctx context.Context, topic string) error {
+	c.mu.Lock()
+	delete(c.subs, topic)
+	c.mu.Unlock()
+
+	return wait(ctx, c.mqtt.Unsubscribe(topic))
+}
+
+// OnReconnect sets the handler called after the connection is restored, e.g. to republish the state
+func (c *Client) OnReconnect(handler func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onReconnect = handler
+}
+
+func (c *Client) Close() {
+	// the clean disconnect doesn't trigger the will
+	if c.status != "" {
+		c.mqtt.Publish(c.status, c.qos, true, StatusOffline).WaitTimeout(StatusTimeout)
+	}
+
+	c.mqtt.Disconnect(DisconnectQuiesce)
+}
+
+// online publishes the status on connect, the token isn't awaited since the connect handler must not block
+func (c *Client) online(l zerolog.Logger) {
+	if c.status == "" {
+		return
+	}
+
+	token := c.mqtt.Publish(c.status, c.qos, true, StatusOnline)
+	go func() {
+		if token.WaitTimeout(StatusTimeout) && token.Error() != nil {
+			l.Warn().Err(token.Error()).Msg("status publish failed")
+		}
+	}()
+}
+
+// Reconnect connects the closed client again restoring the subscriptions, e.g. if its replacement failed
+func (c *Client) Reconnect() error {
+	if token := c.mqtt.Connect(); token.WaitTimeout(ConnectionTimeout) && token.Error() != nil {
+		return fmt.Errorf("MQTT connection failed: %w", token.Error())
+	}
+
+	return nil
+}
+
+func (c *Client) subscribe(topic string, handler MessageHandler) mqtt.Token {
+	return c.mqtt.Subscribe(topic, 1, func(_ mqtt.Client, msg mqtt.Message) {
+		handler(msg.Payload())
+	})
+}
+
+func (c *Client) resubscribe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for topic, handler := range c.subs {
+		topic := topic
+		token := c.subscribe(topic, handler)
+		go func() {
+			if token.WaitTimeout(ConnectionTimeout) && token.Error() != nil {
+				log.Error().Str("name", "mqtt").Str("topic", topic).Err(token.Error()).Msg("resubscribe failed")
+			}
+		}()
+	}
+}
+
+func (c *Client) reconnected() {
+	c.mu.Lock()
+	reconnect, handler := c.connected, c.onReconnect
+	c.connected = true
+	c.mu.Unlock()
+
+	if reconnect && handler != nil {
+		handler()
+	}
+}
+
+// SameClientID reports whether the clients of both configs connect with the same ID,
+// the upstream isn't compared since the broker may be reachable by different URLs
+func (c *Config) SameClientID(other Config) bool {
+	if c.RandomSuffix || other.RandomSuffix {
+		return false
+	}
+
+	id, otherID := c.ClientID, other.ClientID
+	if id == "" {
+		id = DefaultClientID
+	}
+
+	if otherID == "" {
+		otherID = DefaultClientID
+	}
+
+	return id == otherID
+}
+
+func (c *Config) clientID() (string, error) {
+	clientID := c.ClientID
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+
+	if !c.RandomSuffix {
+		return clientID, nil
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	return clientID + "-" + hex.EncodeToString(suffix), nil
+}
+
+func wait(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return fmt.Errorf("canceled: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,487 @@
+package broker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, parent *testCert, tmpl *x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(dir, name+".key")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	require.NoError(t, err)
+
+	return certFile, keyFile
+}
+
+func (c *testCert) tls() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{c.der},
+		PrivateKey:  c.key,
+	}
+}
+
+// serveTLSBroker is the broker stand-in accepting the mutual TLS connections and forwarding the published messages
+func serveTLSBroker(t *testing.T, ca, server *testCert) (string, <-chan *packets.PublishPacket) {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server.tls()},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	published := make(chan *packets.PublishPacket, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveMqtt(conn, nil, published)
+		}
+	}()
+
+	return "ssl://" + ln.Addr().String(), published
+}
+
+func serveMqtt(conn net.Conn, connects chan<- *packets.ConnectPacket, published chan<- *packets.PublishPacket) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for {
+		p, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		var reply packets.ControlPacket
+		switch p := p.(type) {
+		case *packets.ConnectPacket:
+			if connects != nil {
+				connects <- p
+			}
+
+			reply = packets.NewControlPacket(packets.Connack)
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+		case *packets.PublishPacket:
+			if published != nil {
+				published <- p
+			}
+		case *packets.DisconnectPacket:
+			return
+		}
+
+		if reply != nil {
+			if err := reply.Write(conn); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestClient_tls(t *testing.T) {
+	ca := newTestCert(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	server := newTestCert(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "broker"},
+		DNSNames:    []string{"broker.test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	client := newTestCert(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "aweeting"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	dir := t.TempDir()
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := client.write(t, dir, "client")
+
+	upstream, published := serveTLSBroker(t, ca, server)
+
+	cases := []struct {
+		name string
+		tls  TLSConfig
+		ok   bool
+	}{
+		{
+			name: "mutual",
+			tls: TLSConfig{
+				CAFile:     caFile,
+				CertFile:   certFile,
+				KeyFile:    keyFile,
+				ServerName: "broker.test",
+			},
+			ok: true,
+		},
+		{
+			name: "insecure",
+			tls: TLSConfig{
+				CertFile:           certFile,
+				KeyFile:            keyFile,
+				InsecureSkipVerify: true,
+			},
+			ok: true,
+		},
+		{
+			name: "wrong-name",
+			tls: TLSConfig{
+				CAFile:   caFile,
+				CertFile: certFile,
+				KeyFile:  keyFile,
+			},
+		},
+		{
+			name: "no-client-cert",
+			tls: TLSConfig{
+				CAFile:     caFile,
+				ServerName: "broker.test",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewClient(Config{
+				Upstream: upstream,
+				TLS:      tc.tls,
+			})
+			if !tc.ok {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			require.NoError(t, c.Publish(ctx, "awtrix/custom/meetings", false, []byte("{}")))
+			select {
+			case msg := <-published:
+				require.Equal(t, "awtrix/custom/meetings", msg.TopicName)
+			case <-ctx.Done():
+				t.Fatal("message was not published")
+			}
+		})
+	}
+}
+
+func TestClient_session(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	connects := make(chan *packets.ConnectPacket, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		serveMqtt(conn, connects, nil)
+	}()
+
+	c, err := NewClient(Config{
+		Upstream:     "tcp://" + ln.Addr().String(),
+		ClientID:     "office",
+		RandomSuffix: true,
+		QoS:          1,
+		KeepAlive:    42 * time.Second,
+		Will: Will{
+			Topic:  "awtrix/custom/meetings",
+			Retain: true,
+		},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	connect := <-connects
+	require.Regexp(t, `^office-[0-9a-f]{8}$`, connect.ClientIdentifier)
+	require.False(t, connect.CleanSession)
+	require.Equal(t, uint16(42), connect.Keepalive)
+	require.True(t, connect.WillFlag)
+	require.True(t, connect.WillRetain)
+	require.Equal(t, byte(1), connect.WillQos)
+	require.Equal(t, "awtrix/custom/meetings", connect.WillTopic)
+	require.Empty(t, connect.WillMessage)
+}
+
+func TestClient_status(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	connects := make(chan *packets.ConnectPacket, 1)
+	published := make(chan *packets.PublishPacket, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		serveMqtt(conn, connects, published)
+	}()
+
+	c, err := NewClient(Config{
+		Upstream: "tcp://" + ln.Addr().String(),
+		Status:   "aweeting/status",
+		Will: Will{
+			Topic:   "aweeting/status",
+			Payload: StatusOffline,
+			Retain:  true,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "aweeting/status", c.Status())
+
+	connect := <-connects
+	require.Equal(t, "aweeting/status", connect.WillTopic)
+	require.Equal(t, []byte(StatusOffline), connect.WillMessage)
+
+	next := func() *packets.PublishPacket {
+		select {
+		case msg := <-published:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("status was not published")
+			return nil
+		}
+	}
+
+	online := next()
+	require.Equal(t, "aweeting/status", online.TopicName)
+	require.Equal(t, []byte(StatusOnline), online.Payload)
+	require.True(t, online.Retain)
+
+	c.Close()
+	offline := next()
+	require.Equal(t, "aweeting/status", offline.TopicName)
+	require.Equal(t, []byte(StatusOffline), offline.Payload)
+	require.True(t, offline.Retain)
+}
+
+func TestClient_reconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	conns := make(chan net.Conn, 2)
+	connects := make(chan *packets.ConnectPacket, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			conns <- conn
+			go serveMqtt(conn, connects, nil)
+		}
+	}()
+
+	c, err := NewClient(Config{
+		Upstream: "tcp://" + ln.Addr().String(),
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	reconnected := make(chan struct{}, 1)
+	c.OnReconnect(func() {
+		reconnected <- struct{}{}
+	})
+
+	<-connects
+	select {
+	case <-reconnected:
+		t.Fatal("the first connection must not be reported as the reconnect")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// drop the connection on the broker side
+	_ = (<-conns).Close()
+
+	select {
+	case <-reconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("reconnect was not reported")
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	connects := make(chan *packets.ConnectPacket, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveMqtt(conn, connects, nil)
+		}
+	}()
+
+	c, err := NewClient(Config{
+		Upstream: "tcp://" + ln.Addr().String(),
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	reconnected := make(chan struct{}, 1)
+	c.OnReconnect(func() {
+		reconnected <- struct{}{}
+	})
+	<-connects
+
+	// the closed client connects again, e.g. if its replacement failed
+	c.Close()
+	require.NoError(t, c.Reconnect())
+
+	select {
+	case connect := <-connects:
+		require.Equal(t, DefaultClientID, connect.ClientIdentifier)
+	case <-time.After(10 * time.Second):
+		t.Fatal("no second connect")
+	}
+
+	select {
+	case <-reconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("reconnect was not reported")
+	}
+}
+
+func TestConfig_SameClientID(t *testing.T) {
+	cases := []struct {
+		name     string
+		a        Config
+		b        Config
+		expected bool
+	}{
+		{
+			name:     "default",
+			a:        Config{Upstream: "tcp://mqtt.lan:1883"},
+			b:        Config{Upstream: "ssl://mqtt.lan:8883", ClientID: DefaultClientID},
+			expected: true,
+		},
+		{
+			name: "different",
+			a:    Config{ClientID: "aweeting-1"},
+			b:    Config{ClientID: "aweeting-2"},
+		},
+		{
+			name: "random-suffix",
+			a:    Config{},
+			b:    Config{RandomSuffix: true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.a.SameClientID(tc.b))
+		})
+	}
+}
+
+func TestTLSConfig_TLS(t *testing.T) {
+	_, err := (&TLSConfig{CertFile: "client.crt"}).TLS()
+	require.Error(t, err)
+
+	_, err = (&TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).TLS()
+	require.Error(t, err)
+
+	cfg, err := (&TLSConfig{}).TLS()
+	require.NoError(t, err)
+	require.NotNil(t, cfg.RootCAs)
+}
+
+func TestIsSecure(t *testing.T) {
+	cases := map[string]bool{
+		"tcp://localhost:1883":      false,
+		"ws://localhost:8080/mqtt":  false,
+		"ssl://localhost:8883":      true,
+		"tls://localhost:8883":      true,
+		"mqtts://localhost:8883":    true,
+		"wss://localhost:8443/mqtt": true,
+	}
+
+	for upstream, expected := range cases {
+		t.Run(upstream, func(t *testing.T) {
+			require.Equal(t, expected, isSecure(upstream))
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package broker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/buglloc/certifi"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// TLSConfig is used by the ssl://, tls://, mqtts:// and wss:// upstreams
+type TLSConfig struct {
+	// CAFile is the PEM bundle of the broker CA, the bundled certifi pool is used if empty
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key for the mutual TLS auth, optional
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the broker name the certificate is verified against
+	ServerName string
+	// InsecureSkipVerify disables the broker certificate verification, for testing only
+	InsecureSkipVerify bool
+}
+
+// WebsocketConfig is used by the ws:// and wss:// upstreams
+type WebsocketConfig struct {
+	// Headers are the extra HTTP headers of the opening handshake, e.g. the auth token
+	Headers map[string]string
+	// Proxy connects through the proxy from the HTTPS_PROXY (HTTP_PROXY) environment variables
+	Proxy bool
+	// Read and write buffer sizes, the defaults are used if zero
+	ReadBufferSize  int
+	WriteBufferSize int
+}
+
+func (c *TLSConfig) IsZero() bool {
+	return *c == TLSConfig{}
+}
+
+// isSecure reports whether the upstream scheme needs the TLS config
+func isSecure(upstream string) bool {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts", "wss":
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *TLSConfig) TLS() (*tls.Config, error) {
+	out := &tls.Config{
+		RootCAs:            certifi.NewCertPool(),
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA: %w", err)
+		}
+
+		out.RootCAs = x509.NewCertPool()
+		if !out.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", c.CAFile)
+		}
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New(".CertFile and .KeyFile must be set together")
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+
+		out.Certificates = []tls.Certificate{cert}
+	}
+
+	return out, nil
+}
+
+func (c *WebsocketConfig) apply(opts *mqtt.ClientOptions) {
+	if len(c.Headers) > 0 {
+		headers := make(http.Header, len(c.Headers))
+		for k, v := range c.Headers {
+			headers.Set(k, v)
+		}
+
+		opts.SetHTTPHeaders(headers)
+	}
+
+	ws := &mqtt.WebsocketOptions{
+		ReadBufferSize:  c.ReadBufferSize,
+		WriteBufferSize: c.WriteBufferSize,
+	}
+	if c.Proxy {
+		ws.Proxy = http.ProxyFromEnvironment
+	}
+
+	opts.SetWebsocketOptions(ws)
+}
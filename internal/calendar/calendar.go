@@ -9,4 +9,5 @@ const DefaultLimit = 3 * 24 * time.Hour
 
 type Calendar interface {
 	Events(ctx context.Context, limit time.Duration) ([]Event, error)
+	EventsBetween(ctx context.Context, tb TimeBound) ([]Event, error)
 }
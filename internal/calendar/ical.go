@@ -7,7 +7,10 @@ import (
 	"hash/fnv"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"sort"
+	"strings"
 	"time"
 
 	ics "github.com/arran4/golang-ical"
@@ -52,33 +55,28 @@ func NewICal(source string, opts ...Option) (*ICal, error) {
 }
 
 func (c *ICal) Events(ctx context.Context, limit time.Duration) ([]Event, error) {
-	rsp, err := c.httpc.R().
-		SetContext(ctx).
-		Get(c.source)
+	now := time.Now()
+	return c.EventsBetween(ctx, TimeBound{
+		Start: now,
+		End:   now.Add(limit),
+	})
+}
 
+func (c *ICal) EventsBetween(ctx context.Context, tb TimeBound) ([]Event, error) {
+	body, err := c.fetch(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("unable to fetch calendar: %w", err)
+		return nil, err
 	}
-
-	if rsp.IsError() {
-		return nil, fmt.Errorf("non-200 response: %s", rsp.Status())
-	}
-
 	defer func() {
-		_, _ = io.ReadAll(rsp.RawBody())
-		_ = rsp.RawBody().Close()
+		_, _ = io.ReadAll(body)
+		_ = body.Close()
 	}()
 
-	parsed, err := ics.ParseCalendar(rsp.RawBody())
+	parsed, err := ics.ParseCalendar(body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse calendar: %w", err)
 	}
 
-	now := time.Now()
-	tb := TimeBound{
-		Start: now,
-		End:   now.Add(limit),
-	}
 	var events []Event
 	for _, e := range parsed.Events() {
 		var summary string
@@ -86,12 +84,33 @@ func (c *ICal) Events(ctx context.Context, limit time.Duration) ([]Event, error)
 			summary = p.Value
 		}
 
+		var location string
+		if p := e.GetProperty(ics.ComponentPropertyLocation); p != nil {
+			location = p.Value
+		}
+
+		var private bool
+		if p := e.GetProperty(ics.ComponentPropertyClass); p != nil {
+			private = strings.EqualFold(p.Value, string(ics.ClassificationPrivate)) ||
+				strings.EqualFold(p.Value, string(ics.ClassificationConfidential))
+		}
+
+		var transparent bool
+		if p := e.GetProperty(ics.ComponentPropertyTransp); p != nil {
+			transparent = strings.EqualFold(p.Value, string(ics.TransparencyTransparent))
+		}
+
+		categories := eventCategories(e)
 		for _, times := range c.eventTimes(e, tb) {
 			events = append(events, Event{
-				ID:      outEventID(summary, times.Start.UTC().String(), times.End.UTC().String()),
-				Summary: summary,
-				Start:   times.Start.In(c.loc),
-				End:     times.End.In(c.loc),
+				ID:          outEventID(summary, times.Start.UTC().String(), times.End.UTC().String()),
+				Summary:     summary,
+				Location:    location,
+				Categories:  categories,
+				Private:     private,
+				Transparent: transparent,
+				Start:       times.Start.In(c.loc),
+				End:         times.End.In(c.loc),
 			})
 		}
 	}
@@ -115,6 +134,66 @@ func (c *ICal) Events(ctx context.Context, limit time.Duration) ([]Event, error)
 	return events[:n], nil
 }
 
+func (c *ICal) fetch(ctx context.Context) (io.ReadCloser, error) {
+	if path, ok := localPath(c.source); ok {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open calendar: %w", err)
+		}
+
+		return f, nil
+	}
+
+	rsp, err := c.httpc.R().
+		SetContext(ctx).
+		Get(c.source)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch calendar: %w", err)
+	}
+
+	if rsp.IsError() {
+		_ = rsp.RawBody().Close()
+		return nil, fmt.Errorf("non-200 response: %s", rsp.Status())
+	}
+
+	return rsp.RawBody(), nil
+}
+
+// localPath returns the calendar file path for the file:// sources and the existing files
+func localPath(source string) (string, bool) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", false
+	}
+
+	switch u.Scheme {
+	case "file":
+		return u.Path, true
+	case "":
+		if _, err := os.Stat(source); err != nil {
+			return "", false
+		}
+
+		return source, true
+	default:
+		return "", false
+	}
+}
+
+func eventCategories(e *ics.VEvent) []string {
+	var out []string
+	for _, p := range e.GetProperties(ics.ComponentPropertyCategories) {
+		for _, c := range strings.Split(p.Value, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				out = append(out, c)
+			}
+		}
+	}
+
+	return out
+}
+
 func outEventID(summary, eventStart, eventEnd string) int {
 	h := fnv.New32a()
 	_, _ = h.Write([]byte(eventStart))
@@ -0,0 +1,151 @@
+package calendar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//aweeting//test//EN
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Standup
+DTSTART:20240301T090000Z
+DTEND:20240301T091500Z
+RRULE:FREQ=DAILY;COUNT=3
+END:VEVENT
+BEGIN:VEVENT
+UID:review
+SUMMARY:Review
+CLASS:PRIVATE
+DTSTART:20240301T130000Z
+DTEND:20240301T140000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:old
+SUMMARY:Old
+DTSTART:20240201T130000Z
+DTEND:20240201T140000Z
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestLocalPath(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "calendar.ics")
+	require.NoError(t, os.WriteFile(existing, []byte(testCalendar), 0o600))
+
+	cases := []struct {
+		name     string
+		source   string
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "file-url",
+			source:   "file://" + existing,
+			expected: existing,
+			ok:       true,
+		},
+		{
+			name:     "missing-file-url",
+			source:   "file://" + filepath.Join(dir, "missing.ics"),
+			expected: filepath.Join(dir, "missing.ics"),
+			ok:       true,
+		},
+		{
+			name:     "existing-path",
+			source:   existing,
+			expected: existing,
+			ok:       true,
+		},
+		{
+			name:   "missing-path",
+			source: filepath.Join(dir, "missing.ics"),
+		},
+		{
+			name:   "schemeless-url",
+			source: "calendar.example.com/export.ics",
+		},
+		{
+			name:   "https",
+			source: "https://calendar.example.com/export.ics",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := localPath(tc.source)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestICal_localFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	require.NoError(t, os.WriteFile(path, []byte(testCalendar), 0o600))
+
+	for _, source := range []string{path, "file://" + path} {
+		t.Run(source, func(t *testing.T) {
+			cal, err := NewICal(source, WithTimeZone("UTC"))
+			require.NoError(t, err)
+
+			day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+			events, err := cal.EventsBetween(context.Background(), TimeBound{
+				Start: day,
+				End:   day.Add(48 * time.Hour),
+			})
+			require.NoError(t, err)
+
+			type event struct {
+				Summary string
+				Private bool
+				Start   time.Time
+				End     time.Time
+			}
+			actual := make([]event, len(events))
+			for i, e := range events {
+				actual[i] = event{
+					Summary: e.Summary,
+					Private: e.Private,
+					Start:   e.Start,
+					End:     e.End,
+				}
+			}
+
+			require.Equal(t, []event{
+				{
+					Summary: "Standup",
+					Start:   day.Add(9 * time.Hour),
+					End:     day.Add(9*time.Hour + 15*time.Minute),
+				},
+				{
+					Summary: "Review",
+					Private: true,
+					Start:   day.Add(13 * time.Hour),
+					End:     day.Add(14 * time.Hour),
+				},
+				{
+					Summary: "Standup",
+					Start:   day.Add(33 * time.Hour),
+					End:     day.Add(33*time.Hour + 15*time.Minute),
+				},
+			}, actual)
+		})
+	}
+}
+
+func TestICal_missingFile(t *testing.T) {
+	cal, err := NewICal("file://"+filepath.Join(t.TempDir(), "missing.ics"), WithTimeZone("UTC"))
+	require.NoError(t, err)
+
+	_, err = cal.Events(context.Background(), time.Hour)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
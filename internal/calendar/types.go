@@ -5,8 +5,16 @@ import "time"
 type Event struct {
 	ID      int
 	Summary string
-	Start   time.Time
-	End     time.Time
+	// Location of the event, e.g. the room or the call link
+	Location string
+	// Categories of the event, as is
+	Categories []string
+	// Private events are marked with the PRIVATE or CONFIDENTIAL class, their details must not be shown
+	Private bool
+	// Transparent events don't block the time on busy time searches
+	Transparent bool
+	Start       time.Time
+	End         time.Time
 }
 
 func (e *Event) IsSame(other Event) bool {
@@ -10,11 +10,20 @@ import (
 	"github.com/buglloc/aweeting/internal/calendar"
 )
 
+var eventsArgs struct {
+	Profile string
+}
+
 var eventsCmd = &cobra.Command{
 	Use:          "events",
 	SilenceUsage: true,
 	Short:        "Parse&&print upcoming events",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := cfg.Profile(eventsArgs.Profile)
+		if err != nil {
+			return err
+		}
+
 		runtime, err := cfg.NewRuntime()
 		if err != nil {
 			return fmt.Errorf("create runtime: %w", err)
@@ -40,3 +49,8 @@ var eventsCmd = &cobra.Command{
 		return nil
 	},
 }
+
+func init() {
+	flags := eventsCmd.Flags()
+	flags.StringVar(&eventsArgs.Profile, "profile", "", "profile to fetch events of, the first one by default")
+}
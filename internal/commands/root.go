@@ -39,6 +39,7 @@ func init() {
 	rootCmd.AddCommand(
 		startCmd,
 		eventsCmd,
+		simulateCmd,
 	)
 }
 
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/aweeting/internal/config"
+	"github.com/buglloc/aweeting/internal/sink"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var simulateArgs struct {
+	From    string
+	To      string
+	ICS     string
+	Publish bool
+	Speed   float64
+	Profile string
+}
+
+var simulateCmd = &cobra.Command{
+	Use:          "simulate",
+	SilenceUsage: true,
+	Short:        "Replay calendar with a virtual clock and print awtrix payloads",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := cfg.Profile(simulateArgs.Profile)
+		if err != nil {
+			return err
+		}
+
+		if simulateArgs.ICS != "" {
+			cfg.Calendar.SourceURL = simulateArgs.ICS
+		}
+		// simulation must not affect the persisted state
+		cfg.Storage.Path = ""
+
+		loc, err := time.LoadLocation(cfg.Calendar.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+
+		from, err := parseSimulateTime(simulateArgs.From, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+
+		to := from.Add(24 * time.Hour)
+		if simulateArgs.To != "" {
+			to, err = parseSimulateTime(simulateArgs.To, loc)
+			if err != nil {
+				return fmt.Errorf("invalid --to: %w", err)
+			}
+		}
+
+		runtime, err := cfg.NewRuntime()
+		if err != nil {
+			return fmt.Errorf("create runtime: %w", err)
+		}
+
+		defer runtime.Close()
+
+		formatter, err := runtime.NewAwtrixFormatter()
+		if err != nil {
+			return fmt.Errorf("create formatter: %w", err)
+		}
+
+		notifier, err := runtime.NewAwtrixNotifier(config.DefaultSinkName)
+		if err != nil {
+			return fmt.Errorf("create notifier: %w", err)
+		}
+
+		var sinks *sink.Registry
+		speed := float64(0)
+		if simulateArgs.Publish {
+			sinks, err = runtime.NewSinks()
+			if err != nil {
+				return fmt.Errorf("create sinks: %w", err)
+			}
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+
+				sinks.Close(ctx)
+			}()
+
+			speed = simulateArgs.Speed
+		}
+
+		tick, err := runtime.NewSimTicker(from, to, speed)
+		if err != nil {
+			return fmt.Errorf("create ticker: %w", err)
+		}
+
+		handler := func(ctx context.Context, event ticker.Event) error {
+			payload, err := formatter.Payload(event)
+			if err != nil {
+				return fmt.Errorf("payload marshal: %w", err)
+			}
+
+			if payload == nil {
+				payload = []byte("<removed>")
+			}
+
+			fmt.Printf("%s %-12s %s\n", event.Now.Format(time.RFC822), event.State, payload)
+			if notifier != nil {
+				notifications, err := notifier.Notifications(event)
+				if err != nil {
+					return err
+				}
+
+				for _, n := range notifications {
+					fmt.Printf("%s %-12s %s\n", event.Now.Format(time.RFC822), n.Kind, n.Payload)
+					if err := n.Ack(); err != nil {
+						return fmt.Errorf("ack %s notification: %w", n.Kind, err)
+					}
+				}
+			}
+
+			if sinks == nil {
+				return nil
+			}
+
+			return sinks.Handle(ctx, event)
+		}
+
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigChan
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			tick.Stop(ctx)
+		}()
+
+		return tick.Start(handler)
+	},
+}
+
+func init() {
+	flags := simulateCmd.Flags()
+	flags.StringVar(&simulateArgs.From, "from", "", "range start (2006-01-02 or 2006-01-02T15:04), today by default")
+	flags.StringVar(&simulateArgs.To, "to", "", "range end (2006-01-02 or 2006-01-02T15:04), --from + 24h by default")
+	flags.StringVar(&simulateArgs.ICS, "ics", "", "use .ics file instead of configured calendar")
+	flags.BoolVar(&simulateArgs.Publish, "publish", false, "publish events to the configured sinks")
+	flags.Float64Var(&simulateArgs.Speed, "speed", 60, "virtual clock speed multiplier for --publish")
+	flags.StringVar(&simulateArgs.Profile, "profile", "", "profile to simulate, the first one by default")
+}
+
+func parseSimulateTime(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		y, m, d := time.Now().In(loc).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
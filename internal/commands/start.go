@@ -6,60 +6,90 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+
+	"github.com/buglloc/aweeting/internal/app"
+	"github.com/buglloc/aweeting/internal/config"
 )
 
+var startArgs struct {
+	Watch bool
+}
+
 var startCmd = &cobra.Command{
 	Use:          "start",
 	SilenceUsage: true,
 	Short:        "Start API srv",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		runtime, err := cfg.NewRuntime()
-		if err != nil {
-			return fmt.Errorf("create runtime: %w", err)
+		if cfg.Storage.Path == "" && hasAlerts(cfg) {
+			log.Warn().Msg("storage.path is not set, sent alerts are kept in memory and will be repeated after restart")
 		}
 
-		updater, err := runtime.NewAwtrixUpdater()
+		instance, err := app.NewApp(cfg, func() (*config.Config, error) {
+			return config.LoadConfig(rootArgs.Configs...)
+		})
 		if err != nil {
-			return fmt.Errorf("create updater: %w", err)
+			return fmt.Errorf("create app: %w", err)
 		}
 
-		tick, err := runtime.NewTicker()
-		if err != nil {
-			return fmt.Errorf("create ticker: %w", err)
-		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), app.ShutdownTimeout)
+			defer cancel()
 
-		errChan := make(chan error, 1)
-		okChan := make(chan struct{}, 1)
-		go func() {
-			if err := tick.Start(updater.Update); err != nil {
-				errChan <- fmt.Errorf("failed to start application: %w", err)
-			} else {
-				okChan <- struct{}{}
-			}
+			instance.Stop(ctx)
 		}()
 
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if startArgs.Watch {
+			if err := instance.Watch(ctx, rootArgs.Configs); err != nil {
+				return fmt.Errorf("watch config: %w", err)
+			}
+		}
+
+		if err := instance.Start(); err != nil {
+			return fmt.Errorf("start app: %w", err)
+		}
+
 		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 		defer log.Info().Msg("stopped")
 
-		select {
-		case <-sigChan:
-			log.Info().Msg("shutting down gracefully by signal")
-
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-			defer cancel()
+		for {
+			select {
+			case sig := <-sigChan:
+				if sig == syscall.SIGHUP {
+					log.Info().Msg("reloading config by signal")
+					if err := instance.Reload(); err != nil {
+						log.Error().Err(err).Msg("reload failed, keep the current config")
+					}
+					continue
+				}
 
-			tick.Stop(ctx)
-			return nil
-		case <-okChan:
-			return nil
-		case err := <-errChan:
-			return err
+				log.Info().Msg("shutting down gracefully by signal")
+				return nil
+			case err := <-instance.Errors():
+				return err
+			}
 		}
 	},
 }
+
+func hasAlerts(cfg *config.Config) bool {
+	for _, p := range cfg.Pipelines() {
+		if len(p.Awtrix.Alerts.Offsets) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func init() {
+	flags := startCmd.Flags()
+	flags.BoolVar(&startArgs.Watch, "watch", false, "reload on config files change")
+}
@@ -3,9 +3,17 @@ package config
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/buglloc/aweeting/internal/awtrix"
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+const (
+	DefaultTimelineFrom = 8 * time.Hour
+	DefaultTimelineTo   = 20 * time.Hour
 )
 
 type Mqtt struct {
@@ -13,22 +21,463 @@ type Mqtt struct {
 	Username string `koanf:"username"`
 	Password string `koanf:"password"`
 	Topic    string `koanf:"topic"`
+	// Awtrix MQTT prefix, derived from the topic by default
+	Prefix string `koanf:"prefix"`
+	// Topic to receive the manual overrides commands at, disabled if empty
+	CommandTopic string        `koanf:"commandTopic"`
+	TLS          MqttTLS       `koanf:"tls"`
+	Websocket    MqttWebsocket `koanf:"websocket"`
+	// Client ID, unique per broker, "aweeting" by default
+	ClientID string `koanf:"clientId"`
+	// Append the random suffix to the client ID, so the instances don't kick each other off
+	RandomSuffix bool `koanf:"randomSuffix"`
+	// QoS of the published messages, 0-2
+	QoS int `koanf:"qos"`
+	// Retain the awtrix custom apps messages, so the device gets them back after reboot
+	Retain       bool `koanf:"retain"`
+	CleanSession bool `koanf:"cleanSession"`
+	// Zero durations fall back to the client defaults
+	KeepAlive      time.Duration `koanf:"keepAlive"`
+	ConnectTimeout time.Duration `koanf:"connectTimeout"`
+	WriteTimeout   time.Duration `koanf:"writeTimeout"`
+	Will           MqttWill      `koanf:"will"`
+	// Status topic of the connection, the availability of the homeassistant sinks, "<clientId>/status" by default
+	Status string `koanf:"status"`
+}
+
+// MqttWill is the last will published by the broker if aweeting dies uncleanly
+type MqttWill struct {
+	Enabled bool `koanf:"enabled"`
+	// Topic of the will, mqtt.topic by default or mqtt.status with the homeassistant sinks
+	Topic string `koanf:"topic"`
+	// Payload of the will, the empty one removes the awtrix app
+	Payload string `koanf:"payload"`
+}
+
+// MqttTLS is used by the ssl://, tls://, mqtts:// and wss:// upstreams
+type MqttTLS struct {
+	// PEM bundle of the broker CA, the bundled certifi pool is used if empty
+	CAFile string `koanf:"caFile"`
+	// PEM client certificate and key for the mutual TLS auth
+	CertFile string `koanf:"certFile"`
+	KeyFile  string `koanf:"keyFile"`
+	// Broker name the certificate is verified against, the upstream host by default
+	ServerName string `koanf:"serverName"`
+	// Disables the broker certificate verification, for testing only
+	InsecureSkipVerify bool `koanf:"insecureSkipVerify"`
+}
+
+// MqttWebsocket is used by the ws:// and wss:// upstreams
+type MqttWebsocket struct {
+	// Extra HTTP headers of the opening handshake
+	Headers map[string]string `koanf:"headers"`
+	// Connect through the proxy from the HTTPS_PROXY (HTTP_PROXY) environment variables
+	Proxy           bool `koanf:"proxy"`
+	ReadBufferSize  int  `koanf:"readBufferSize"`
+	WriteBufferSize int  `koanf:"writeBufferSize"`
 }
 
 type Awtrix struct {
-	SelfDestruct  bool              `koanf:"selfDestruct"`
+	SelfDestruct bool `koanf:"selfDestruct"`
+	// Deprecated: use ticker.upcomingLimit
 	UpcomingLimit time.Duration     `koanf:"upcomingLimit"`
 	Messages      AwtrixMessagesSet `koanf:"messages"`
+	Alerts        AwtrixAlerts      `koanf:"alerts"`
+	Transitions   AwtrixTransitions `koanf:"transitions"`
+	Buttons       AwtrixButtons     `koanf:"buttons"`
+	Title         AwtrixTitle       `koanf:"title"`
+	Durations     AwtrixDurations   `koanf:"durations"`
+	Loop          AwtrixLoop        `koanf:"loop"`
+	Timeline      AwtrixTimeline    `koanf:"timeline"`
+	Display       AwtrixDisplay     `koanf:"display"`
+}
+
+// AwtrixDisplay dims or powers off the display outside the ticker working hours
+type AwtrixDisplay struct {
+	// Brightness during the working hours 1-255, the auto brightness is used if zero
+	Brightness int `koanf:"brightness"`
+	// Dim is the brightness outside the working hours 1-255, disabled if zero
+	Dim int `koanf:"dim"`
+	// PowerOff turns the matrix off outside the working hours
+	PowerOff bool `koanf:"powerOff"`
+	// WakeBefore wakes the display up before the meeting starting off hours, 15m by default
+	WakeBefore time.Duration `koanf:"wakeBefore"`
+}
+
+func (c *AwtrixDisplay) Enabled() bool {
+	return c.Dim > 0 || c.PowerOff
+}
+
+// AwtrixTimeline is the day timeline drawn by the timeline sink
+type AwtrixTimeline struct {
+	// Shown range in the calendar timezone, e.g. "09:00" - "19:00", the working hours or 08:00 - 20:00 by default
+	From string `koanf:"from"`
+	To   string `koanf:"to"`
+	// Duration of the app in the loop in seconds
+	Duration int                  `koanf:"duration"`
+	Colors   AwtrixTimelineColors `koanf:"colors"`
+}
+
+// AwtrixTimelineColors are the timeline colors (#hex), the empty color is not drawn
+type AwtrixTimelineColors struct {
+	Free    string `koanf:"free"`
+	Elapsed string `koanf:"elapsed"`
+	Meeting string `koanf:"meeting"`
+	Focus   string `koanf:"focus"`
+	Current string `koanf:"current"`
+	Now     string `koanf:"now"`
+	Hours   string `koanf:"hours"`
+}
+
+// AwtrixLoop controls the device apps loop during the meetings
+type AwtrixLoop struct {
+	// Switch jumps to the meeting app when the meeting begins
+	Switch bool `koanf:"switch"`
+	// Pin disables the apps auto transition during the meetings and enables it back afterwards
+	Pin bool `koanf:"pin"`
+}
+
+// AwtrixDurations are the duration formats of the message templates
+type AwtrixDurations struct {
+	Default AwtrixDuration `koanf:"default"`
+	// Optional formats per state, the base state or the default format is used if empty
+	Upcoming     *AwtrixDuration `koanf:"upcoming"`
+	StartingSoon *AwtrixDuration `koanf:"startingSoon"`
+	OnAir        *AwtrixDuration `koanf:"onAir"`
+	WrappingUp   *AwtrixDuration `koanf:"wrappingUp"`
+	Focus        *AwtrixDuration `koanf:"focus"`
+}
+
+type AwtrixDuration struct {
+	// Style is one of clock (HH:MM, default), compact (1h5m), minutes (45m) or days (2d)
+	Style string `koanf:"style"`
+	// Rounding is one of floor (default), ceil or nearest
+	Rounding string `koanf:"rounding"`
+}
+
+func (c *AwtrixDuration) Format() awtrix.DurationFormat {
+	return awtrix.DurationFormat{
+		Style:    awtrix.DurationStyle(c.Style),
+		Rounding: awtrix.DurationRounding(c.Rounding),
+	}
+}
+
+// Formats returns the configured formats per state
+func (c *AwtrixDurations) Formats() map[ticker.State]awtrix.DurationFormat {
+	optional := map[ticker.State]*AwtrixDuration{
+		ticker.StateUpcoming:     c.Upcoming,
+		ticker.StateStartingSoon: c.StartingSoon,
+		ticker.StateOnAir:        c.OnAir,
+		ticker.StateWrappingUp:   c.WrappingUp,
+		ticker.StateFocus:        c.Focus,
+	}
+
+	out := make(map[ticker.State]awtrix.DurationFormat)
+	for state, d := range optional {
+		if d != nil {
+			out[state] = d.Format()
+		}
+	}
+
+	return out
+}
+
+func (c *AwtrixDurations) Validate() error {
+	if err := c.Default.Format().Validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+
+	for state, format := range c.Formats() {
+		if err := format.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", state, err)
+		}
+	}
+
+	return nil
+}
+
+// AwtrixTitle shows the meeting title when the meeting begins
+type AwtrixTitle struct {
+	// Ticks is the number of the first on-air ticks showing the title instead of the countdown, disabled if zero
+	Ticks int `koanf:"ticks"`
+	// Notify sends the title as the one-shot notification on the on-air transition
+	Notify bool `koanf:"notify"`
+	// Redacted replaces the summary of the private meetings everywhere, including the message templates
+	Redacted string `koanf:"redacted"`
+	// Message of the title, the text is "{{ .Summary }}" by default
+	Message AwtrixMessage `koanf:"message"`
+}
+
+// Validate checks the message text templates and the duration formats
+func (c *Awtrix) Validate() error {
+	if err := c.Durations.Validate(); err != nil {
+		return fmt.Errorf("durations.%w", err)
+	}
+
+	if err := c.Messages.Stages.Validate(); err != nil {
+		return fmt.Errorf("messages.stages.%w", err)
+	}
+
+	if _, _, err := c.Timeline.Range(WorkingHours{}); err != nil {
+		return fmt.Errorf("timeline: %w", err)
+	}
+
+	texts := []struct {
+		key string
+		msg *AwtrixMessage
+	}{
+		{"messages.none", &c.Messages.None},
+		{"messages.upcoming", &c.Messages.Upcoming},
+		{"messages.onAir", &c.Messages.OnAir},
+		{"messages.focus", &c.Messages.Focus},
+		{"messages.startingSoon", c.Messages.StartingSoon},
+		{"messages.wrappingUp", c.Messages.WrappingUp},
+		{"messages.stale", c.Messages.Stale},
+		{"messages.offHours", c.Messages.OffHours},
+		{"alerts.message", &c.Alerts.Message},
+		{"transitions.started.message", &c.Transitions.Started.Message},
+		{"transitions.ended.message", &c.Transitions.Ended.Message},
+		{"title.message", &c.Title.Message},
+	}
+
+	for _, t := range texts {
+		if t.msg == nil {
+			continue
+		}
+
+		if _, err := awtrix.ParseText(t.key, t.msg.Text); err != nil {
+			return fmt.Errorf("%s.text: invalid template: %w", t.key, err)
+		}
+	}
+
+	return nil
+}
+
+type AwtrixButtons struct {
+	Left   AwtrixButtonAction `koanf:"left"`
+	Select AwtrixButtonAction `koanf:"select"`
+	Right  AwtrixButtonAction `koanf:"right"`
+}
+
+// AwtrixButtonAction is the command to apply on the button press, e.g. {action: snooze, for: 15m}
+type AwtrixButtonAction struct {
+	// One of free, snooze, busy, clear or refresh, disabled if empty
+	Action string        `koanf:"action"`
+	For    time.Duration `koanf:"for"`
+	Until  string        `koanf:"until"`
+}
+
+// Commands returns the commands of the configured buttons
+func (c *AwtrixButtons) Commands() (map[awtrix.Button]ticker.Command, error) {
+	buttons := map[awtrix.Button]AwtrixButtonAction{
+		awtrix.ButtonLeft:   c.Left,
+		awtrix.ButtonSelect: c.Select,
+		awtrix.ButtonRight:  c.Right,
+	}
+
+	out := make(map[awtrix.Button]ticker.Command)
+	for button, a := range buttons {
+		cmd := ticker.Command{
+			Action: ticker.CommandAction(a.Action),
+			For:    a.For,
+			Until:  a.Until,
+		}
+
+		switch cmd.Action {
+		case "":
+			continue
+		case ticker.CommandFree, ticker.CommandDismiss, ticker.CommandClear, ticker.CommandRefresh:
+		case ticker.CommandBusy, ticker.CommandSnooze:
+			if cmd.For <= 0 && cmd.Until == "" {
+				return nil, fmt.Errorf("%s: .For or .Until is required", button)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unsupported action: %q", button, cmd.Action)
+		}
+
+		out[button] = cmd
+	}
+
+	return out, nil
+}
+
+type AwtrixAlerts struct {
+	// Offsets before the meeting start to notify at
+	Offsets []time.Duration `koanf:"offsets"`
+	Message AwtrixMessage   `koanf:"message"`
 }
 
 type AwtrixMessagesSet struct {
 	None     AwtrixMessage `koanf:"none"`
 	Upcoming AwtrixMessage `koanf:"upcoming"`
 	OnAir    AwtrixMessage `koanf:"onAir"`
+	Focus    AwtrixMessage `koanf:"focus"`
+	// Optional messages for the derived states, the base state message is used if empty
+	StartingSoon *AwtrixMessage `koanf:"startingSoon"`
+	WrappingUp   *AwtrixMessage `koanf:"wrappingUp"`
+	Stale        *AwtrixMessage `koanf:"stale"`
+	OffHours     *AwtrixMessage `koanf:"offHours"`
+	// Stages override the state message fields by the time left
+	Stages AwtrixStagesSet `koanf:"stages"`
+}
+
+// AwtrixIndicatorsSet are the indicator LED settings per state, the base state one is used if empty, the LED is off if none
+type AwtrixIndicatorsSet struct {
+	None         *AwtrixIndicator `koanf:"none"`
+	Upcoming     *AwtrixIndicator `koanf:"upcoming"`
+	StartingSoon *AwtrixIndicator `koanf:"startingSoon"`
+	OnAir        *AwtrixIndicator `koanf:"onAir"`
+	WrappingUp   *AwtrixIndicator `koanf:"wrappingUp"`
+	Focus        *AwtrixIndicator `koanf:"focus"`
+	Stale        *AwtrixIndicator `koanf:"stale"`
+	OffHours     *AwtrixIndicator `koanf:"offHours"`
+}
+
+type AwtrixIndicator struct {
+	// The color (#hex)
+	Color string `koanf:"color"`
+	// Blinks the indicator in the given interval in ms
+	Blink int `koanf:"blink"`
+	// Fades the indicator in the given interval in ms
+	Fade int `koanf:"fade"`
+}
+
+// DefaultIndicators lights the indicator solid red while on-air and blinking yellow when the meeting is starting soon
+func DefaultIndicators() AwtrixIndicatorsSet {
+	return AwtrixIndicatorsSet{
+		StartingSoon: &AwtrixIndicator{
+			Color: "#ffff00",
+			Blink: 500,
+		},
+		OnAir: &AwtrixIndicator{
+			Color: "#ff0000",
+		},
+	}
+}
+
+func (c *AwtrixIndicatorsSet) IsZero() bool {
+	return len(c.Indicators()) == 0
+}
+
+// Indicators returns the configured indicators per state
+func (c *AwtrixIndicatorsSet) Indicators() map[ticker.State]awtrix.Indicator {
+	in := map[ticker.State]*AwtrixIndicator{
+		ticker.StateIdle:         c.None,
+		ticker.StateUpcoming:     c.Upcoming,
+		ticker.StateStartingSoon: c.StartingSoon,
+		ticker.StateOnAir:        c.OnAir,
+		ticker.StateWrappingUp:   c.WrappingUp,
+		ticker.StateFocus:        c.Focus,
+		ticker.StateStale:        c.Stale,
+		ticker.StateOffHours:     c.OffHours,
+	}
+
+	out := make(map[ticker.State]awtrix.Indicator)
+	for state, i := range in {
+		if i == nil {
+			continue
+		}
+
+		ind := awtrix.Indicator(*i)
+		if ind.Color == "" {
+			ind = awtrix.IndicatorOff
+		}
+
+		out[state] = ind
+	}
+
+	return out
+}
+
+// AwtrixStagesSet are the threshold stages per state, the base state stages are used if empty
+type AwtrixStagesSet struct {
+	Upcoming     []AwtrixStage `koanf:"upcoming"`
+	StartingSoon []AwtrixStage `koanf:"startingSoon"`
+	OnAir        []AwtrixStage `koanf:"onAir"`
+	WrappingUp   []AwtrixStage `koanf:"wrappingUp"`
+	Focus        []AwtrixStage `koanf:"focus"`
+}
+
+// AwtrixStage overrides the message fields while the time left is at or below the threshold, the tightest matching stage wins.
+// The time left is the time to the start for the upcoming states and the time to the end for the on-air and focus ones
+type AwtrixStage struct {
+	Below time.Duration `koanf:"below"`
+	// Message fields to override, e.g. {color: "#ff0000", blinkText: 300}
+	Message map[string]any `koanf:"message"`
+}
+
+// Stages returns the configured stages per state
+func (c *AwtrixStagesSet) Stages() map[ticker.State][]awtrix.Stage {
+	in := map[ticker.State][]AwtrixStage{
+		ticker.StateUpcoming:     c.Upcoming,
+		ticker.StateStartingSoon: c.StartingSoon,
+		ticker.StateOnAir:        c.OnAir,
+		ticker.StateWrappingUp:   c.WrappingUp,
+		ticker.StateFocus:        c.Focus,
+	}
+
+	out := make(map[ticker.State][]awtrix.Stage)
+	for state, stages := range in {
+		for _, st := range stages {
+			out[state] = append(out[state], awtrix.Stage{
+				Below:     st.Below,
+				Overrides: st.Message,
+			})
+		}
+	}
+
+	return out
+}
+
+func (c *AwtrixStagesSet) Validate() error {
+	for state, stages := range c.Stages() {
+		for i, st := range stages {
+			if err := st.Validate(); err != nil {
+				return fmt.Errorf("%s.%d: %w", state, i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *AwtrixMessagesSet) Payloads() map[ticker.State]awtrix.Payload {
+	out := map[ticker.State]awtrix.Payload{
+		ticker.StateIdle:     awtrix.Payload(c.None),
+		ticker.StateUpcoming: awtrix.Payload(c.Upcoming),
+		ticker.StateOnAir:    awtrix.Payload(c.OnAir),
+		ticker.StateFocus:    awtrix.Payload(c.Focus),
+	}
+
+	optional := map[ticker.State]*AwtrixMessage{
+		ticker.StateStartingSoon: c.StartingSoon,
+		ticker.StateWrappingUp:   c.WrappingUp,
+		ticker.StateStale:        c.Stale,
+		ticker.StateOffHours:     c.OffHours,
+	}
+	for state, msg := range optional {
+		if msg != nil {
+			out[state] = awtrix.Payload(*msg)
+		}
+	}
+
+	return out
+}
+
+type AwtrixTransitions struct {
+	// Notify on the upcoming -> on-air transition
+	Started AwtrixNotification `koanf:"started"`
+	// Notify on the on-air -> none transition
+	Ended AwtrixNotification `koanf:"ended"`
+}
+
+type AwtrixNotification struct {
+	Enabled bool          `koanf:"enabled"`
+	Message AwtrixMessage `koanf:"message"`
 }
 
 type AwtrixMessage struct {
-	// The text to display
+	// The text template to display, see awtrix.ParseText
 	Text string `koanf:"text"`
 	// Changes the Uppercase setting. 0=global setting, 1=forces uppercase; 2=shows as it sent
 	TextCase int `koanf:"textCase"`
@@ -42,14 +491,30 @@ type AwtrixMessage struct {
 	Background string `koanf:"background"`
 	// Fades each letter in the text differently through the entire RGB spectrum
 	Rainbow bool `koanf:"rainbow"`
+	// Blinks the text in an given interval in ms, not compatible with gradient or rainbow
+	BlinkText int `koanf:"blinkText"`
 	// The icon ID or filename (without extension) to display on the app
 	Icon string `koanf:"icon"`
 	// 0 = Icon doesn't move. 1 = Icon moves with text and will not appear again. 2 = Icon moves with text but appears again when the text starts to scroll again.
 	PushIcon int `koanf:"pushIcon"`
+	// Shows a progress bar, value can be 0-100. Filled by the meeting progress if the progress color is set
+	Progress int `koanf:"progress"`
+	// The color of the progress bar (#hex)
+	ProgressC string `koanf:"progressC"`
+	// The color of the progress bar background (#hex)
+	ProgressBC string `koanf:"progressBC"`
 	// Sets how many times the text should be scrolled through the matrix before the app ends
 	Repeat int `koanf:"repeat"`
 	// Sets how long the app or notification should be displayed
 	Duration int `koanf:"duration"`
+	// Set it to true, to hold your **notification** on top until you press the middle button or dismiss it via HomeAssistant. This key only belongs to notification
+	Hold bool `koanf:"hold"`
+	// The filename of your RTTTL ringtone file placed in the MELODIES folder (without extension)
+	Sound string `koanf:"sound"`
+	// Allows to send the RTTTL sound string with the json
+	Rtttl string `koanf:"rtttl"`
+	// Loops the sound or rtttl as long as the notification is running
+	LoopSound bool `koanf:"loopSound"`
 	// Enables or disables autoscaling for bar and linechart
 	Autoscale bool `koanf:"autoscale"`
 	//  Defines the position of your custompage in the loop, starting at 0 for the first position. This will only apply with your first push. This function is experimental
@@ -75,27 +540,325 @@ func (c *Mqtt) Validate() error {
 		return errors.New(".Upstream is required")
 	}
 
-	if c.Topic == "" {
-		return errors.New(".Topic is required")
+	if c.QoS < 0 || c.QoS > 2 {
+		return fmt.Errorf("invalid QoS: %d", c.QoS)
 	}
 
 	return nil
 }
 
-func (r *Runtime) NewAwtrixUpdater() (*awtrix.MqttUpdater, error) {
-	if err := r.cfg.Mqtt.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid mqtt config: %w", err)
+// BrokerConfig returns the connection settings, shared between all the profiles
+func (c *Mqtt) BrokerConfig() broker.Config {
+	return broker.Config{
+		Upstream:       c.Upstream,
+		Username:       c.Username,
+		Password:       c.Password,
+		TLS:            broker.TLSConfig(c.TLS),
+		Websocket:      broker.WebsocketConfig(c.Websocket),
+		ClientID:       c.ClientID,
+		RandomSuffix:   c.RandomSuffix,
+		QoS:            byte(c.QoS),
+		CleanSession:   c.CleanSession,
+		KeepAlive:      c.KeepAlive,
+		ConnectTimeout: c.ConnectTimeout,
+		WriteTimeout:   c.WriteTimeout,
+		Will:           c.will(),
+	}
+}
+
+func (c *Mqtt) will() broker.Will {
+	if !c.Will.Enabled {
+		return broker.Will{}
+	}
+
+	topic := c.Will.Topic
+	if topic == "" {
+		topic = c.Topic
+	}
+
+	return broker.Will{
+		Topic:   topic,
+		Payload: c.Will.Payload,
+		Retain:  c.Retain,
+	}
+}
+
+// StatusTopic returns the connection status topic
+func (c *Mqtt) StatusTopic() string {
+	if c.Status != "" {
+		return c.Status
+	}
+
+	clientID := c.ClientID
+	if clientID == "" {
+		clientID = broker.DefaultClientID
+	}
+
+	return clientID + "/status"
+}
+
+func (c *Mqtt) AwtrixPrefix() string {
+	return awtrixPrefix(c.Prefix, c.Topic)
+}
+
+// awtrixPrefix derives the awtrix MQTT prefix from the custom app topic, e.g. "awtrix/custom/meetings" -> "awtrix"
+func awtrixPrefix(prefix, topic string) string {
+	if prefix != "" {
+		return prefix
+	}
+
+	if idx := strings.Index(topic, "/custom/"); idx > 0 {
+		return topic[:idx]
+	}
+
+	return ""
+}
+
+// awtrixApp returns the custom app name of the awtrix topic
+func awtrixApp(topic string) string {
+	if idx := strings.Index(topic, "/custom/"); idx >= 0 {
+		return topic[idx+len("/custom/"):]
+	}
+
+	return ""
+}
+
+func (r *Runtime) NewAwtrixUpdater(sc Sink) (awtrix.Updater, error) {
+	formatter, err := r.NewAwtrixFormatter()
+	if err != nil {
+		return nil, fmt.Errorf("create formatter: %w", err)
+	}
+
+	notifier, err := r.NewAwtrixNotifier(sc.Name)
+	if err != nil {
+		return nil, fmt.Errorf("create notifier: %w", err)
+	}
+
+	controls, err := r.NewAwtrixControls(sc.App)
+	if err != nil {
+		return nil, err
+	}
+
+	switch sc.Transport {
+	case AwtrixTransportMqtt:
+		client, err := r.MqttClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return awtrix.NewMqttUpdater(awtrix.UpdaterConfig{
+			Client:    client,
+			Topic:     sc.Topic,
+			Prefix:    awtrixPrefix(sc.Prefix, sc.Topic),
+			Formatter: formatter,
+			Notifier:  notifier,
+			Controls:  controls,
+			Retain:    r.cfg.Mqtt.Retain,
+		})
+	case AwtrixTransportHttp:
+		return awtrix.NewHttpUpdater(awtrix.HttpUpdaterConfig{
+			URL:         sc.URL,
+			App:         sc.App,
+			Username:    sc.Username,
+			Password:    sc.Password,
+			Timeout:     sc.Timeout,
+			Retries:     sc.Retries,
+			ResendAfter: sc.ResendAfter,
+			Formatter:   formatter,
+			Notifier:    notifier,
+			Controls:    controls,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported transport: %q", sc.Transport)
+	}
+}
+
+// Range returns the shown range offsets from the midnight
+func (c *AwtrixTimeline) Range(workingHours WorkingHours) (time.Duration, time.Duration, error) {
+	from, to := c.From, c.To
+	inherited := from == "" && to == ""
+	if inherited {
+		from, to = workingHours.From, workingHours.To
+	}
+
+	if from == "" && to == "" {
+		return DefaultTimelineFrom, DefaultTimelineTo, nil
+	}
+
+	fromOff, err := parseClock(from)
+	if err != nil {
+		return 0, 0, fmt.Errorf(".From: %w", err)
+	}
+
+	toOff, err := parseClock(to)
+	if err != nil {
+		return 0, 0, fmt.Errorf(".To: %w", err)
+	}
+
+	if inherited && toOff < fromOff {
+		// the timeline can't cross the midnight, so the overnight shifts fall back to the default range
+		return DefaultTimelineFrom, DefaultTimelineTo, nil
+	}
+
+	if fromOff >= toOff {
+		return 0, 0, fmt.Errorf("invalid range: %s -> %s", from, to)
+	}
+
+	return fromOff, toOff, nil
+}
+
+func (r *Runtime) NewAwtrixTimeline(sc Sink) (*awtrix.TimelineUpdater, error) {
+	loc, err := time.LoadLocation(r.cfg.Calendar.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	timeline := r.cfg.Awtrix.Timeline
+	from, to, err := timeline.Range(r.cfg.Ticker.WorkingHours)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeline range: %w", err)
+	}
+
+	client, err := r.MqttClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return awtrix.NewTimelineUpdater(awtrix.TimelineUpdaterConfig{
+		Client:   client,
+		Topic:    sc.Topic,
+		Location: loc,
+		From:     from,
+		To:       to,
+		Duration: timeline.Duration,
+		Colors:   awtrix.TimelineColors(timeline.Colors),
+		Retain:   r.cfg.Mqtt.Retain,
+	})
+}
+
+// NewAwtrixControls returns the configured device controls of the app
+func (r *Runtime) NewAwtrixControls(app string) ([]awtrix.Controller, error) {
+	var out []awtrix.Controller
+	appControl, err := r.NewAwtrixAppControl(app)
+	if err != nil {
+		return nil, fmt.Errorf("create app control: %w", err)
+	}
+
+	if appControl != nil {
+		out = append(out, appControl)
+	}
+
+	displayControl, err := r.NewAwtrixDisplayControl()
+	if err != nil {
+		return nil, fmt.Errorf("create display control: %w", err)
+	}
+
+	if displayControl != nil {
+		out = append(out, displayControl)
+	}
+
+	return out, nil
+}
+
+// NewAwtrixDisplayControl returns nil if the display control is not configured
+func (r *Runtime) NewAwtrixDisplayControl() (*awtrix.DisplayControl, error) {
+	display := r.cfg.Awtrix.Display
+	if !display.Enabled() {
+		return nil, nil
+	}
+
+	states, err := r.NewMachineConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return awtrix.NewDisplayControl(awtrix.DisplayControlConfig{
+		WorkingHours: states.WorkingHours,
+		WakeBefore:   display.WakeBefore,
+		Brightness:   display.Brightness,
+		Dim:          display.Dim,
+		PowerOff:     display.PowerOff,
+	})
+}
+
+// NewAwtrixAppControl returns nil if the apps loop control is not configured
+func (r *Runtime) NewAwtrixAppControl(app string) (*awtrix.AppControl, error) {
+	loop := r.cfg.Awtrix.Loop
+	if !loop.Switch && !loop.Pin {
+		return nil, nil
+	}
+
+	return awtrix.NewAppControl(awtrix.AppControlConfig{
+		App:    app,
+		Switch: loop.Switch,
+		Pin:    loop.Pin,
+	})
+}
+
+// NewAwtrixNotifier returns nil if notifications are not configured
+func (r *Runtime) NewAwtrixNotifier(name string) (*awtrix.Notifier, error) {
+	alerter, err := r.NewAwtrixAlerter(name)
+	if err != nil {
+		return nil, fmt.Errorf("create alerter: %w", err)
+	}
+
+	transitions := r.cfg.Awtrix.Transitions
+	title := r.cfg.Awtrix.Title
+	if alerter == nil && !transitions.Started.Enabled && !transitions.Ended.Enabled && !title.Notify {
+		return nil, nil
+	}
+
+	formatter, err := r.NewAwtrixFormatter()
+	if err != nil {
+		return nil, fmt.Errorf("create formatter: %w", err)
+	}
+
+	return awtrix.NewNotifier(awtrix.NotifierConfig{
+		Formatter: formatter,
+		Alerter:   alerter,
+		Started:   transitions.Started.Enabled,
+		Ended:     transitions.Ended.Enabled,
+		Title:     title.Notify,
+	}), nil
+}
+
+// NewAwtrixAlerter returns nil if alerts are not configured
+func (r *Runtime) NewAwtrixAlerter(name string) (*awtrix.Alerter, error) {
+	if len(r.cfg.Awtrix.Alerts.Offsets) == 0 {
+		return nil, nil
+	}
+
+	s, err := r.Storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return awtrix.NewAlerter(awtrix.AlerterConfig{
+		Offsets:    r.cfg.Awtrix.Alerts.Offsets,
+		Storage:    s,
+		StorageKey: r.storageKey(name + ".alerts"),
+	})
+}
+
+func (r *Runtime) NewAwtrixFormatter() (*awtrix.Formatter, error) {
+	loc, err := time.LoadLocation(r.cfg.Calendar.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
 	}
 
-	return awtrix.NewMqttUpdater(awtrix.UpdaterConfig{
-		Upstream:        r.cfg.Mqtt.Upstream,
-		Username:        r.cfg.Mqtt.Username,
-		Password:        r.cfg.Mqtt.Password,
-		Topic:           r.cfg.Mqtt.Topic,
+	return awtrix.NewFormatter(awtrix.FormatterConfig{
 		SelfDestruct:    r.cfg.Awtrix.SelfDestruct,
-		UpcomingLimit:   r.cfg.Awtrix.UpcomingLimit,
-		NonePayload:     awtrix.Payload(r.cfg.Awtrix.Messages.None),
-		UpcomingPayload: awtrix.Payload(r.cfg.Awtrix.Messages.Upcoming),
-		OnAirPayload:    awtrix.Payload(r.cfg.Awtrix.Messages.OnAir),
+		UpcomingLimit:   r.upcomingLimit(),
+		Location:        loc,
+		Durations:       r.cfg.Awtrix.Durations.Formats(),
+		DefaultDuration: r.cfg.Awtrix.Durations.Default.Format(),
+		Payloads:        r.cfg.Awtrix.Messages.Payloads(),
+		Stages:          r.cfg.Awtrix.Messages.Stages.Stages(),
+		AlertPayload:    awtrix.Payload(r.cfg.Awtrix.Alerts.Message),
+		StartedPayload:  awtrix.Payload(r.cfg.Awtrix.Transitions.Started.Message),
+		EndedPayload:    awtrix.Payload(r.cfg.Awtrix.Transitions.Ended.Message),
+		TitleTicks:      r.cfg.Awtrix.Title.Ticks,
+		TitlePayload:    awtrix.Payload(r.cfg.Awtrix.Title.Message),
+		Redacted:        r.cfg.Awtrix.Title.Redacted,
 	})
 }
@@ -1,36 +1,126 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+	"github.com/rs/zerolog/log"
 
 	"github.com/buglloc/aweeting/internal/awtrix"
+	"github.com/buglloc/aweeting/internal/broker"
 	"github.com/buglloc/aweeting/internal/calendar"
+	"github.com/buglloc/aweeting/internal/storage"
 	"github.com/buglloc/aweeting/internal/ticker"
 )
 
 type Config struct {
+	// Name of the profile, empty for the top-level config
+	Name     string   `koanf:"name"`
 	Verbose  bool     `koanf:"verbose"`
 	Calendar Calendar `koanf:"calendar"`
 	Ticker   Ticker   `koanf:"ticker"`
 	Mqtt     Mqtt     `koanf:"mqtt"`
 	Awtrix   Awtrix   `koanf:"awtrix"`
+	Storage  Storage  `koanf:"storage"`
+	Sinks    []Sink   `koanf:"sinks"`
+	// Profiles are the independent calendar -> display pipelines, each inherits the top-level config
+	Profiles []*Config `koanf:"-"`
 }
 
 func (c *Config) Validate() error {
+	if err := c.Awtrix.Validate(); err != nil {
+		return fmt.Errorf("awtrix: %w", err)
+	}
+
+	if c.Awtrix.Display.Enabled() && c.Ticker.WorkingHours.From == "" {
+		return errors.New("awtrix.display requires ticker.workingHours")
+	}
+
 	return nil
 }
 
 type Runtime struct {
 	cfg *Config
+	// parent owns the MQTT connection and the storage shared between the profiles
+	parent    *Runtime
+	storage   *storage.Storage
+	mqtt      *broker.Client
+	overrides *ticker.Overrides
+	// machine is the state machine of the built ticker, the next one continues from its state
+	machine *ticker.Machine
+	// handover is the previous MQTT connection with the same client ID, it's closed before this one connects
+	handover   *broker.Client
+	handedOver bool
 }
 
 func LoadConfig(files ...string) (*Config, error) {
+	k := koanf.New(".")
+
+	yamlParser := yaml.Parser()
+	for _, fpath := range files {
+		if err := k.Load(file.Provider(fpath), yamlParser); err != nil {
+			return nil, fmt.Errorf("load %q config: %w", fpath, err)
+		}
+	}
+
+	envCb := func(s string) string {
+		return strings.Replace(strings.ToLower(
+			strings.TrimPrefix(s, "AW_")), "_", ".", -1)
+	}
+	if err := k.Load(env.Provider("AW_", ".", envCb), nil); err != nil {
+		return nil, fmt.Errorf("load env config: %w", err)
+	}
+
+	base := k.Copy()
+	base.Delete("profiles")
+
+	out, err := unmarshalConfig(base)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{})
+	for i, pk := range k.Slices("profiles") {
+		merged := base.Copy()
+		if err := merged.Merge(pk); err != nil {
+			return nil, fmt.Errorf("merge profile %d: %w", i, err)
+		}
+
+		profile, err := unmarshalConfig(merged)
+		if err != nil {
+			return nil, fmt.Errorf("profile %d: %w", i, err)
+		}
+
+		if err := profile.validateProfile(out); err != nil {
+			return nil, fmt.Errorf("profile %d: %w", i, err)
+		}
+
+		if _, ok := names[profile.Name]; ok {
+			return nil, fmt.Errorf("profile %d: duplicate name %q", i, profile.Name)
+		}
+
+		names[profile.Name] = struct{}{}
+		out.Profiles = append(out.Profiles, profile)
+	}
+
+	if err := validateNodeIDs(out.Pipelines()); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if err := validateStatus(out); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return out, nil
+}
+
+func unmarshalConfig(k *koanf.Koanf) (*Config, error) {
 	out := Config{
 		Calendar: Calendar{
 			Timezone: calendar.DefaultTimezone,
@@ -41,34 +131,116 @@ func LoadConfig(files ...string) (*Config, error) {
 			FetchInterval: ticker.DefaultFetchInterval,
 			TickInterval:  ticker.DefaultTickInterval,
 		},
+		Mqtt: Mqtt{
+			CleanSession: true,
+			Will: MqttWill{
+				Enabled: true,
+			},
+		},
 		Awtrix: Awtrix{
-			UpcomingLimit: awtrix.DefaultUpcomingLimit,
+			UpcomingLimit: ticker.DefaultUpcomingLimit,
 			Messages: AwtrixMessagesSet{
 				None:     AwtrixMessage(awtrix.DefaultPayload),
 				Upcoming: AwtrixMessage(awtrix.DefaultPayload),
 				OnAir:    AwtrixMessage(awtrix.DefaultPayload),
+				Focus:    AwtrixMessage(awtrix.DefaultPayload),
+			},
+			Alerts: AwtrixAlerts{
+				Message: AwtrixMessage(awtrix.DefaultPayload),
+			},
+			Transitions: AwtrixTransitions{
+				Started: AwtrixNotification{
+					Message: AwtrixMessage(awtrix.DefaultPayload),
+				},
+				Ended: AwtrixNotification{
+					Message: AwtrixMessage(awtrix.DefaultPayload),
+				},
+			},
+			Title: AwtrixTitle{
+				Message: AwtrixMessage(awtrix.DefaultPayload),
+			},
+			Timeline: AwtrixTimeline{
+				Colors: AwtrixTimelineColors{
+					Free:    "#002000",
+					Elapsed: "#101010",
+					Meeting: "#800000",
+					Focus:   "#000080",
+					Current: "#ff0000",
+					Now:     "#ffffff",
+					Hours:   "#404040",
+				},
 			},
 		},
 	}
 
-	k := koanf.New(".")
-
-	yamlParser := yaml.Parser()
-	for _, fpath := range files {
-		if err := k.Load(file.Provider(fpath), yamlParser); err != nil {
-			return nil, fmt.Errorf("load %q config: %w", fpath, err)
+	// optional messages must inherit defaults only if they are present
+	optionalMessages := map[string]**AwtrixMessage{
+		"awtrix.messages.startingSoon": &out.Awtrix.Messages.StartingSoon,
+		"awtrix.messages.wrappingUp":   &out.Awtrix.Messages.WrappingUp,
+		"awtrix.messages.stale":        &out.Awtrix.Messages.Stale,
+		"awtrix.messages.offHours":     &out.Awtrix.Messages.OffHours,
+	}
+	for key, msg := range optionalMessages {
+		if k.Exists(key) {
+			defaultMsg := AwtrixMessage(awtrix.DefaultPayload)
+			*msg = &defaultMsg
 		}
 	}
 
-	envCb := func(s string) string {
-		return strings.Replace(strings.ToLower(
-			strings.TrimPrefix(s, "AW_")), "_", ".", -1)
+	if err := k.Unmarshal("", &out); err != nil {
+		return nil, err
 	}
-	if err := k.Load(env.Provider("AW_", ".", envCb), nil); err != nil {
-		return nil, fmt.Errorf("load env config: %w", err)
+
+	if err := out.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return &out, nil
+}
+
+// validateProfile checks that the profile doesn't override the settings shared between the profiles
+func (c *Config) validateProfile(root *Config) error {
+	if c.Name == "" {
+		return errors.New(".Name is required")
+	}
+
+	// the last will belongs to the top-level config, since the connection is shared
+	profileBroker, rootBroker := c.Mqtt.BrokerConfig(), root.Mqtt.BrokerConfig()
+	profileBroker.Will, rootBroker.Will = broker.Will{}, broker.Will{}
+	if !reflect.DeepEqual(profileBroker, rootBroker) {
+		return errors.New("mqtt connection settings are shared and can't be overridden")
+	}
+
+	if c.Storage != root.Storage {
+		return errors.New("storage settings are shared and can't be overridden")
+	}
+
+	return nil
+}
+
+// Pipelines returns the configs of the calendar -> display pipelines: the profiles or the config itself
+func (c *Config) Pipelines() []*Config {
+	if len(c.Profiles) == 0 {
+		return []*Config{c}
+	}
+
+	return c.Profiles
+}
+
+// Profile returns the pipeline config by the profile name, the first one if name is empty
+func (c *Config) Profile(name string) (*Config, error) {
+	pipelines := c.Pipelines()
+	if name == "" {
+		return pipelines[0], nil
+	}
+
+	for _, p := range pipelines {
+		if p.Name == name {
+			return p, nil
+		}
 	}
 
-	return &out, k.Unmarshal("", &out)
+	return nil, fmt.Errorf("profile %q not found", name)
 }
 
 func (c *Config) NewRuntime() (*Runtime, error) {
@@ -80,3 +252,157 @@ func (c *Config) NewRuntime() (*Runtime, error) {
 		cfg: c,
 	}, nil
 }
+
+// Profile returns the runtime of the profile sharing the MQTT connection and the storage with this one
+func (r *Runtime) Profile(cfg *Config) (*Runtime, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return &Runtime{
+		cfg:    cfg,
+		parent: r.root(),
+	}, nil
+}
+
+func (r *Runtime) MqttClient() (*broker.Client, error) {
+	if r.parent != nil {
+		return r.parent.MqttClient()
+	}
+
+	if r.mqtt != nil {
+		return r.mqtt, nil
+	}
+
+	if err := r.cfg.Mqtt.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mqtt config: %w", err)
+	}
+
+	// the broker kicks off one of the clients with the same ID, so the previous one must go first
+	if r.handover != nil && !r.handedOver {
+		log.Info().Msg("closing the previous MQTT connection to reuse its client ID")
+		r.handover.Close()
+		r.handedOver = true
+	}
+
+	client, err := broker.NewClient(r.cfg.BrokerConfig())
+	if err != nil {
+		return nil, fmt.Errorf("create mqtt client: %w", err)
+	}
+
+	r.mqtt = client
+	return client, nil
+}
+
+// Adopt reuses the MQTT connection, the storage and the overrides of the previous runtime if their configs weren't changed,
+// the ticker state is always carried over. Profile runtimes must adopt after their parents.
+func (r *Runtime) Adopt(prev *Runtime) {
+	r.machine = prev.machine
+	if r.parent == nil {
+		newBroker, prevBroker := r.cfg.BrokerConfig(), prev.cfg.BrokerConfig()
+		switch {
+		case reflect.DeepEqual(newBroker, prevBroker):
+			r.mqtt = prev.mqtt
+		case prev.mqtt != nil && newBroker.SameClientID(prevBroker):
+			r.handover = prev.mqtt
+		}
+
+		if r.cfg.Storage == prev.cfg.Storage {
+			r.storage = prev.storage
+		}
+	}
+
+	if r.root().storage == prev.root().storage && r.cfg.Calendar.Timezone == prev.cfg.Calendar.Timezone {
+		r.overrides = prev.overrides
+	}
+}
+
+// OpenedMqtt returns the MQTT client if some component already uses it, nil otherwise
+func (r *Runtime) OpenedMqtt() *broker.Client {
+	return r.root().mqtt
+}
+
+// SharesMqtt reports whether both runtimes use the same MQTT connection
+func (r *Runtime) SharesMqtt(other *Runtime) bool {
+	return r.root().mqtt != nil && r.root().mqtt == other.root().mqtt
+}
+
+func (r *Runtime) Close() {
+	r.CloseExcept(nil)
+}
+
+// Discard closes the runtime which failed to replace the previous one and reconnects the MQTT connection handed over to it
+func (r *Runtime) Discard(prev *Runtime) {
+	r.CloseExcept(prev)
+	if !r.handedOver {
+		return
+	}
+
+	r.handedOver = false
+	if err := r.handover.Reconnect(); err != nil {
+		log.Error().Err(err).Msg("unable to restore the previous MQTT connection")
+	}
+}
+
+// CloseExcept closes the runtime components which are not shared with the other runtime.
+// Profile runtimes own nothing, the shared components are closed by the parent.
+func (r *Runtime) CloseExcept(other *Runtime) {
+	if r.parent != nil || r.mqtt == nil {
+		return
+	}
+
+	if other != nil && other.root().mqtt == r.mqtt {
+		return
+	}
+
+	r.mqtt.Close()
+}
+
+func (r *Runtime) root() *Runtime {
+	if r.parent != nil {
+		return r.parent
+	}
+
+	return r
+}
+
+// storageKey makes the storage key unique per profile
+func (r *Runtime) storageKey(key string) string {
+	if r.cfg.Name == "" {
+		return key
+	}
+
+	return r.cfg.Name + "." + key
+}
+
+// Changes describes which runtime components are affected by the config change
+type Changes struct {
+	Mqtt   bool
+	Sinks  bool
+	Ticker bool
+}
+
+func (c Changes) IsZero() bool {
+	return !c.Mqtt && !c.Sinks && !c.Ticker
+}
+
+func (c *Config) Changes(prev *Config) Changes {
+	out := Changes{
+		Mqtt: !reflect.DeepEqual(c.Mqtt, prev.Mqtt),
+	}
+
+	out.Sinks = out.Mqtt ||
+		!reflect.DeepEqual(c.Awtrix, prev.Awtrix) ||
+		!reflect.DeepEqual(c.Sinks, prev.Sinks) ||
+		!reflect.DeepEqual(c.Storage, prev.Storage)
+
+	out.Ticker = !reflect.DeepEqual(c.Calendar, prev.Calendar) ||
+		!reflect.DeepEqual(c.Ticker, prev.Ticker) ||
+		!reflect.DeepEqual(c.Storage, prev.Storage) ||
+		!reflect.DeepEqual(c.Awtrix.Alerts.Offsets, prev.Awtrix.Alerts.Offsets) ||
+		!reflect.DeepEqual(c.Awtrix.Messages.Stages, prev.Awtrix.Messages.Stages) ||
+		c.Awtrix.Display != prev.Awtrix.Display ||
+		c.Awtrix.UpcomingLimit != prev.Awtrix.UpcomingLimit
+
+	return out
+}
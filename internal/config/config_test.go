@@ -0,0 +1,340 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestLoadConfig_profiles(t *testing.T) {
+	load := func(t *testing.T, body string) (*Config, error) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+		return LoadConfig(path)
+	}
+
+	cfg, err := load(t, `
+calendar:
+  sourceUrl: https://example.com/common.ics
+mqtt:
+  upstream: tcp://localhost:1883
+  topic: awtrix/custom/meetings
+profiles:
+  - name: alice
+  - name: bob
+    calendar:
+      sourceUrl: https://example.com/bob.ics
+    mqtt:
+      topic: bob/custom/meetings
+`)
+	require.NoError(t, err)
+	require.Len(t, cfg.Pipelines(), 2)
+
+	alice, err := cfg.Profile("")
+	require.NoError(t, err)
+	require.Equal(t, "alice", alice.Name)
+	require.Equal(t, "https://example.com/common.ics", alice.Calendar.SourceURL)
+	require.Equal(t, "awtrix/custom/meetings", alice.Mqtt.Topic)
+
+	bob, err := cfg.Profile("bob")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/bob.ics", bob.Calendar.SourceURL)
+	require.Equal(t, "bob/custom/meetings", bob.Mqtt.Topic)
+	require.Equal(t, cfg.Calendar.Timezone, bob.Calendar.Timezone)
+	require.Equal(t, []string{"bob"}, bob.AwtrixPrefixes())
+
+	_, err = load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+profiles:
+  - name: alice
+    mqtt:
+      upstream: tcp://other:1883
+`)
+	require.Error(t, err)
+
+	_, err = load(t, `
+profiles:
+  - name: alice
+  - name: alice
+`)
+	require.Error(t, err)
+}
+
+func TestLoadConfig_nodeIDs(t *testing.T) {
+	load := func(t *testing.T, body string) (*Config, error) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+		return LoadConfig(path)
+	}
+
+	cfg, err := load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+sinks:
+  - kind: homeassistant
+profiles:
+  - name: alice
+  - name: bob
+`)
+	require.NoError(t, err)
+
+	var nodeIDs, topics []string
+	for _, p := range cfg.Pipelines() {
+		sc := p.ResolvedSinks()[0]
+		nodeIDs = append(nodeIDs, sc.Discovery.NodeID)
+		topics = append(topics, sc.Topic)
+	}
+	require.Equal(t, []string{"aweeting_alice", "aweeting_bob"}, nodeIDs)
+	require.Equal(t, []string{"aweeting/aweeting_alice", "aweeting/aweeting_bob"}, topics)
+
+	cfg, err = load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+sinks:
+  - kind: homeassistant
+`)
+	require.NoError(t, err)
+	require.Equal(t, "aweeting", cfg.ResolvedSinks()[0].Discovery.NodeID)
+
+	_, err = load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+sinks:
+  - kind: homeassistant
+    discovery:
+      nodeId: office
+profiles:
+  - name: alice
+  - name: bob
+`)
+	require.ErrorContains(t, err, `node ID "office"`)
+}
+
+func TestLoadConfig_status(t *testing.T) {
+	load := func(t *testing.T, body string) (*Config, error) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+		return LoadConfig(path)
+	}
+
+	cfg, err := load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+  topic: awtrix/custom/meetings
+`)
+	require.NoError(t, err)
+	require.Empty(t, cfg.BrokerConfig().Status)
+	require.Equal(t, broker.Will{Topic: "awtrix/custom/meetings"}, cfg.BrokerConfig().Will)
+
+	cfg, err = load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+  topic: awtrix/custom/meetings
+  clientId: office
+profiles:
+  - name: alice
+    sinks:
+      - kind: homeassistant
+`)
+	require.NoError(t, err)
+	require.Equal(t, "office/status", cfg.BrokerConfig().Status)
+	require.Equal(t, broker.Will{Topic: "office/status", Payload: broker.StatusOffline, Retain: true}, cfg.BrokerConfig().Will)
+
+	_, err = load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+  will:
+    topic: awtrix/custom/meetings
+sinks:
+  - kind: homeassistant
+`)
+	require.ErrorContains(t, err, "mqtt.will.topic")
+
+	_, err = load(t, `
+mqtt:
+  upstream: tcp://localhost:1883
+  will:
+    enabled: false
+sinks:
+  - kind: homeassistant
+`)
+	require.ErrorContains(t, err, "mqtt.will to be enabled")
+}
+
+func TestLoadConfig_templates(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		err  string
+	}{
+		{
+			name: "valid",
+			text: "{{ .Summary }} {{ humanize .Left }} till {{ clock .EndsAt }}",
+		},
+		{
+			name: "syntax",
+			text: "{{ .Summary",
+			err:  "messages.onAir.text: invalid template",
+		},
+		{
+			name: "field",
+			text: "{{ .Title }}",
+			err:  "can't evaluate field Title",
+		},
+		{
+			name: "func",
+			text: "{{ shout .Summary }}",
+			err:  `function "shout" not defined`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			body := "awtrix:\n  messages:\n    onAir:\n      text: '" + tc.text + "'\n"
+			require.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+
+			_, err := LoadConfig(path)
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestConfig_Changes(t *testing.T) {
+	const base = `
+calendar:
+  sourceUrl: https://example.com/common.ics
+mqtt:
+  upstream: tcp://localhost:1883
+  topic: awtrix/custom/meetings
+`
+	load := func(t *testing.T, body string) *Config {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o644))
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		return cfg
+	}
+
+	const workingHours = `
+ticker:
+  workingHours:
+    from: "09:00"
+    to: "19:00"
+`
+	cases := []struct {
+		name string
+		// prev is appended to the base of the previous config
+		prev     string
+		extra    string
+		expected Changes
+	}{
+		{
+			name: "nothing",
+		},
+		{
+			name: "mqtt",
+			extra: `
+  retain: true
+`,
+			expected: Changes{Mqtt: true, Sinks: true},
+		},
+		{
+			name: "awtrix",
+			extra: `
+awtrix:
+  messages:
+    none:
+      color: "#00ff00"
+`,
+			expected: Changes{Sinks: true},
+		},
+		{
+			name: "ticker",
+			extra: `
+ticker:
+  jitter: 5m
+`,
+			expected: Changes{Ticker: true},
+		},
+		{
+			name: "alerts",
+			extra: `
+awtrix:
+  alerts:
+    offsets: [5m]
+`,
+			expected: Changes{Sinks: true, Ticker: true},
+		},
+		{
+			name: "display",
+			prev: workingHours,
+			extra: workingHours + `
+awtrix:
+  display:
+    dim: 10
+`,
+			expected: Changes{Sinks: true, Ticker: true},
+		},
+		{
+			name: "storage",
+			extra: `
+storage:
+  path: /tmp/state.json
+`,
+			expected: Changes{Sinks: true, Ticker: true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := load(t, base+tc.extra).Changes(load(t, base+tc.prev))
+			require.Equal(t, tc.expected, actual)
+			require.Equal(t, tc.extra == "", actual.IsZero())
+		})
+	}
+}
+
+func TestRuntime_Adopt(t *testing.T) {
+	newRuntime := func(t *testing.T, mqtt Mqtt) *Runtime {
+		cfg := &Config{
+			Calendar: Calendar{SourceURL: "https://example.com/common.ics", Timezone: "UTC"},
+			Mqtt:     mqtt,
+		}
+		r, err := cfg.NewRuntime()
+		require.NoError(t, err)
+		return r
+	}
+
+	prev := newRuntime(t, Mqtt{Upstream: "tcp://localhost:1883"})
+	prev.mqtt = &broker.Client{}
+	prev.machine = ticker.NewMachine(ticker.MachineConfig{})
+
+	same := newRuntime(t, Mqtt{Upstream: "tcp://localhost:1883"})
+	same.Adopt(prev)
+	require.Same(t, prev.mqtt, same.mqtt)
+	require.Nil(t, same.handover)
+	require.Same(t, prev.machine, same.machine)
+
+	// the new connection with the same client ID must replace the previous one
+	moved := newRuntime(t, Mqtt{Upstream: "ssl://localhost:8883"})
+	moved.Adopt(prev)
+	require.Nil(t, moved.mqtt)
+	require.Same(t, prev.mqtt, moved.handover)
+
+	suffixed := newRuntime(t, Mqtt{Upstream: "ssl://localhost:8883", RandomSuffix: true})
+	suffixed.Adopt(prev)
+	require.Nil(t, suffixed.mqtt)
+	require.Nil(t, suffixed.handover)
+}
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/awtrix"
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/sink"
+)
+
+type SinkKind string
+
+const (
+	SinkKindAwtrix    SinkKind = "awtrix"
+	SinkKindIndicator SinkKind = "indicator"
+	SinkKindTimeline  SinkKind = "timeline"
+	SinkKindHA        SinkKind = "homeassistant"
+	SinkKindState     SinkKind = "state"
+	SinkKindWebhook   SinkKind = "webhook"
+	SinkKindStdout    SinkKind = "stdout"
+)
+
+const (
+	DefaultSinkName  = "awtrix"
+	DefaultIndicator = 1
+	// DefaultTimelineApp is the custom app name of the timeline sink
+	DefaultTimelineApp = "timeline"
+)
+
+type AwtrixTransport string
+
+const (
+	AwtrixTransportMqtt AwtrixTransport = "mqtt"
+	AwtrixTransportHttp AwtrixTransport = "http"
+)
+
+type Sink struct {
+	Name string   `koanf:"name"`
+	Kind SinkKind `koanf:"kind"`
+	// MQTT topic for the awtrix and state sinks, mqtt.topic by default.
+	// The timeline sink uses the "timeline" custom app of the awtrix prefix by default,
+	// the homeassistant sink uses "aweeting/<nodeId>" as the base of its topics
+	Topic string `koanf:"topic"`
+	// Awtrix MQTT prefix, derived from the topic by default
+	Prefix string `koanf:"prefix"`
+	// Awtrix transport, mqtt by default. The http one posts to the awtrix API at the URL
+	Transport AwtrixTransport `koanf:"transport"`
+	// Awtrix custom app name for the http transport, derived from the topic by default
+	App string `koanf:"app"`
+	// Awtrix indicator LED for the indicator sink, 1-3
+	Indicator int `koanf:"indicator"`
+	// Indicator sink colors per state
+	Indicators AwtrixIndicatorsSet `koanf:"indicators"`
+	// Basic auth of the awtrix http API
+	Username string `koanf:"username"`
+	Password string `koanf:"password"`
+	// Retries of the failed awtrix http requests, negative disables
+	Retries int `koanf:"retries"`
+	// Resend period of the unchanged awtrix http app, so the rebooted device gets it back, 30m by default, negative disables
+	ResendAfter time.Duration `koanf:"resendAfter"`
+	// Publish retained messages, state sink only
+	Retain bool `koanf:"retain"`
+	// Home Assistant discovery settings of the homeassistant sink
+	Discovery HomeAssistantDiscovery `koanf:"discovery"`
+	// Webhook or awtrix http sink settings
+	URL     string            `koanf:"url"`
+	Headers map[string]string `koanf:"headers"`
+	Timeout time.Duration     `koanf:"timeout"`
+}
+
+type HomeAssistantDiscovery struct {
+	// Discovery prefix of Home Assistant, "homeassistant" by default
+	Prefix string `koanf:"prefix"`
+	// Node ID, unique per Home Assistant, "aweeting" by default or "aweeting_<profile name>" for the profiles
+	NodeID string              `koanf:"nodeId"`
+	Device HomeAssistantDevice `koanf:"device"`
+}
+
+type HomeAssistantDevice struct {
+	Name          string `koanf:"name"`
+	Manufacturer  string `koanf:"manufacturer"`
+	Model         string `koanf:"model"`
+	SuggestedArea string `koanf:"suggestedArea"`
+}
+
+func (c *Sink) Validate() error {
+	switch c.Kind {
+	case SinkKindAwtrix:
+		switch c.Transport {
+		case AwtrixTransportMqtt:
+			if c.Topic == "" {
+				return errors.New(".Topic is required")
+			}
+		case AwtrixTransportHttp:
+			if c.URL == "" {
+				return errors.New(".URL is required")
+			}
+
+			if c.App == "" {
+				return errors.New(".App is required")
+			}
+		default:
+			return fmt.Errorf("unsupported transport: %q", c.Transport)
+		}
+	case SinkKindIndicator:
+		if c.Topic == "" && c.Prefix == "" {
+			return errors.New(".Topic or .Prefix is required")
+		}
+
+		if c.Indicator < 1 || c.Indicator > 3 {
+			return fmt.Errorf("invalid indicator: %d", c.Indicator)
+		}
+	case SinkKindTimeline:
+		if c.Topic == "" {
+			return errors.New(".Topic is required")
+		}
+	case SinkKindHA:
+		if c.Topic == "" {
+			return errors.New(".Topic is required")
+		}
+	case SinkKindState:
+		if c.Topic == "" {
+			return errors.New(".Topic is required")
+		}
+	case SinkKindWebhook:
+		if c.URL == "" {
+			return errors.New(".URL is required")
+		}
+	case SinkKindStdout:
+	default:
+		return fmt.Errorf("unsupported kind: %q", c.Kind)
+	}
+
+	return nil
+}
+
+// ResolvedSinks returns the configured sinks with the defaults applied, the single awtrix sink if none configured
+func (c *Config) ResolvedSinks() []Sink {
+	sinks := c.Sinks
+	if len(sinks) == 0 {
+		sinks = []Sink{
+			{
+				Name: DefaultSinkName,
+				Kind: SinkKindAwtrix,
+			},
+		}
+	}
+
+	out := make([]Sink, len(sinks))
+	for i, sc := range sinks {
+		if sc.Name == "" {
+			sc.Name = fmt.Sprintf("%s-%d", sc.Kind, i)
+		}
+
+		if sc.Kind == SinkKindTimeline && sc.Topic == "" {
+			if prefix := awtrixPrefix(sc.Prefix, c.Mqtt.Topic); prefix != "" {
+				sc.Topic = prefix + "/custom/" + DefaultTimelineApp
+			}
+		}
+
+		if sc.Kind == SinkKindHA {
+			if sc.Discovery.NodeID == "" {
+				sc.Discovery.NodeID = sink.DefaultNodeID
+				// each profile is the separate Home Assistant device
+				if c.Name != "" {
+					sc.Discovery.NodeID += "_" + c.Name
+				}
+			}
+
+			if sc.Topic == "" {
+				sc.Topic = "aweeting/" + sc.Discovery.NodeID
+			}
+		}
+
+		if sc.Topic == "" && sc.Kind != SinkKindTimeline {
+			sc.Topic = c.Mqtt.Topic
+		}
+
+		if sc.Kind == SinkKindIndicator {
+			if sc.Indicator == 0 {
+				sc.Indicator = DefaultIndicator
+			}
+
+			if sc.Indicators.IsZero() {
+				sc.Indicators = DefaultIndicators()
+			}
+		}
+
+		if sc.Kind == SinkKindAwtrix {
+			if sc.Transport == "" {
+				sc.Transport = AwtrixTransportMqtt
+			}
+
+			if sc.App == "" {
+				sc.App = awtrixApp(sc.Topic)
+			}
+		}
+
+		out[i] = sc
+	}
+
+	return out
+}
+
+// BrokerConfig returns the connection settings of the config and its profiles. The homeassistant sinks need the connection
+// status for their availability, so the will goes to the status topic then instead of removing the awtrix app.
+func (c *Config) BrokerConfig() broker.Config {
+	out := c.Mqtt.BrokerConfig()
+	if !hasHomeAssistant(c.Pipelines()) {
+		return out
+	}
+
+	out.Status = c.Mqtt.StatusTopic()
+	out.Will = broker.Will{
+		Topic:   out.Status,
+		Payload: broker.StatusOffline,
+		Retain:  true,
+	}
+	return out
+}
+
+// validateStatus checks that the will of the connection with the homeassistant sinks goes to the status topic
+func validateStatus(c *Config) error {
+	if !hasHomeAssistant(c.Pipelines()) {
+		return nil
+	}
+
+	if !c.Mqtt.Will.Enabled {
+		return errors.New("homeassistant sinks require mqtt.will to be enabled")
+	}
+
+	if c.Mqtt.Will.Topic != "" && c.Mqtt.Will.Topic != c.Mqtt.StatusTopic() {
+		return fmt.Errorf("homeassistant sinks require mqtt.will.topic to be the status topic %q", c.Mqtt.StatusTopic())
+	}
+
+	return nil
+}
+
+func hasHomeAssistant(pipelines []*Config) bool {
+	for _, p := range pipelines {
+		for _, sc := range p.ResolvedSinks() {
+			if sc.Kind == SinkKindHA {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateNodeIDs checks that the homeassistant sinks of the pipelines don't overwrite each other's discovery configs
+func validateNodeIDs(pipelines []*Config) error {
+	owners := make(map[string]string)
+	for _, p := range pipelines {
+		for _, sc := range p.ResolvedSinks() {
+			if sc.Kind != SinkKindHA {
+				continue
+			}
+
+			owner := fmt.Sprintf("sink %q", sc.Name)
+			if p.Name != "" {
+				owner = fmt.Sprintf("profile %q %s", p.Name, owner)
+			}
+
+			if prev, ok := owners[sc.Discovery.NodeID]; ok {
+				return fmt.Errorf("homeassistant node ID %q is used by both %s and %s", sc.Discovery.NodeID, prev, owner)
+			}
+
+			owners[sc.Discovery.NodeID] = owner
+		}
+	}
+
+	return nil
+}
+
+// AwtrixPrefixes returns the unique MQTT prefixes of the awtrix sinks using the mqtt transport
+func (c *Config) AwtrixPrefixes() []string {
+	var out []string
+	seen := make(map[string]struct{})
+	for _, sc := range c.ResolvedSinks() {
+		if sc.Kind != SinkKindAwtrix || sc.Transport != AwtrixTransportMqtt {
+			continue
+		}
+
+		prefix := awtrixPrefix(sc.Prefix, sc.Topic)
+		if _, ok := seen[prefix]; ok || prefix == "" {
+			continue
+		}
+
+		seen[prefix] = struct{}{}
+		out = append(out, prefix)
+	}
+
+	return out
+}
+
+func (r *Runtime) NewSinks() (*sink.Registry, error) {
+	reg := sink.NewRegistry()
+	for _, sc := range r.cfg.ResolvedSinks() {
+		s, err := r.NewSink(sc)
+		if err != nil {
+			reg.Close(context.Background())
+			return nil, fmt.Errorf("create sink %q: %w", sc.Name, err)
+		}
+
+		reg.Register(sc.Name, s)
+	}
+
+	return reg, nil
+}
+
+func (r *Runtime) NewHomeAssistant(sc Sink) (*sink.HomeAssistant, error) {
+	loc, err := time.LoadLocation(r.cfg.Calendar.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	client, err := r.MqttClient()
+	if err != nil {
+		return nil, err
+	}
+
+	redacted := r.cfg.Awtrix.Title.Redacted
+	if redacted == "" {
+		redacted = awtrix.DefaultRedacted
+	}
+
+	device := sc.Discovery.Device
+	return sink.NewHomeAssistant(sink.HomeAssistantConfig{
+		Client:          client,
+		Topic:           sc.Topic,
+		DiscoveryPrefix: sc.Discovery.Prefix,
+		NodeID:          sc.Discovery.NodeID,
+		Device: sink.HomeAssistantDevice{
+			Name:          device.Name,
+			Manufacturer:  device.Manufacturer,
+			Model:         device.Model,
+			SuggestedArea: device.SuggestedArea,
+		},
+		Location: loc,
+		Redacted: redacted,
+	})
+}
+
+func (r *Runtime) NewSink(sc Sink) (sink.Sink, error) {
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	switch sc.Kind {
+	case SinkKindAwtrix:
+		return r.NewAwtrixUpdater(sc)
+	case SinkKindIndicator:
+		client, err := r.MqttClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return awtrix.NewIndicatorUpdater(awtrix.IndicatorUpdaterConfig{
+			Client:     client,
+			Prefix:     awtrixPrefix(sc.Prefix, sc.Topic),
+			Index:      sc.Indicator,
+			Indicators: sc.Indicators.Indicators(),
+		})
+	case SinkKindTimeline:
+		return r.NewAwtrixTimeline(sc)
+	case SinkKindHA:
+		return r.NewHomeAssistant(sc)
+	case SinkKindState:
+		client, err := r.MqttClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return sink.NewState(sink.StateConfig{
+			Client: client,
+			Topic:  sc.Topic,
+			Retain: sc.Retain,
+		})
+	case SinkKindWebhook:
+		return sink.NewWebhook(sink.WebhookConfig{
+			URL:     sc.URL,
+			Headers: sc.Headers,
+			Timeout: sc.Timeout,
+		})
+	case SinkKindStdout:
+		return sink.NewStdout(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unsupported kind: %q", sc.Kind)
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/buglloc/aweeting/internal/storage"
+)
+
+type Storage struct {
+	// Path to the state file, keeps state in memory if empty
+	Path string `koanf:"path"`
+}
+
+func (r *Runtime) Storage() (*storage.Storage, error) {
+	if r.parent != nil {
+		return r.parent.Storage()
+	}
+
+	if r.storage != nil {
+		return r.storage, nil
+	}
+
+	s, err := storage.NewStorage(r.cfg.Storage.Path)
+	if err != nil {
+		return nil, fmt.Errorf("create storage: %w", err)
+	}
+
+	r.storage = s
+	return s, nil
+}
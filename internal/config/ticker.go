@@ -3,8 +3,11 @@ package config
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/buglloc/aweeting/internal/awtrix"
 	"github.com/buglloc/aweeting/internal/ticker"
 )
 
@@ -13,6 +16,52 @@ type Ticker struct {
 	PreviewLimit  time.Duration `koanf:"previewLimit"`
 	FetchInterval time.Duration `koanf:"fetchInterval"`
 	TickInterval  time.Duration `koanf:"tickInterval"`
+	// Meetings starting later are idle, awtrix.upcomingLimit is used if empty
+	UpcomingLimit time.Duration `koanf:"upcomingLimit"`
+	// Upcoming meetings starting within this threshold are "starting soon"
+	StartingSoon time.Duration `koanf:"startingSoon"`
+	// Meetings ending within this threshold are "wrapping up"
+	WrappingUp time.Duration `koanf:"wrappingUp"`
+	// Events are "stale" if calendar wasn't fetched for the given duration
+	StaleAfter   time.Duration `koanf:"staleAfter"`
+	WorkingHours WorkingHours  `koanf:"workingHours"`
+	Classes      Classes       `koanf:"classes"`
+}
+
+type Classes struct {
+	// Rules of the focus time events, any matched rule makes the event a focus block
+	Focus []ClassRule `koanf:"focus"`
+	// Class winning when a focus block overlaps a meeting: "meeting" (default) or "focus"
+	Priority string `koanf:"priority"`
+}
+
+// ClassRule matches the event if all of its non-empty conditions match
+type ClassRule struct {
+	// Regexp of the event summary, e.g. "(?i)^focus"
+	Summary string `koanf:"summary"`
+	// Event category, case insensitive
+	Category string `koanf:"category"`
+	// Match only the transparent (free) events
+	Transparent bool `koanf:"transparent"`
+}
+
+type WorkingHours struct {
+	// Start of the working hours in the calendar timezone, e.g. "09:00"
+	From string `koanf:"from"`
+	// End of the working hours in the calendar timezone, e.g. "19:00"
+	To string `koanf:"to"`
+	// Working days, e.g. ["mon", "tue", "wed", "thu", "fri"], every day if empty
+	Days []string `koanf:"days"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
 }
 
 func (c *Ticker) Validate() error {
@@ -28,9 +77,164 @@ func (c *Ticker) Validate() error {
 		return errors.New(".TickInterval is required")
 	}
 
+	if _, err := c.WorkingHours.Parse(time.Local); err != nil {
+		return fmt.Errorf(".WorkingHours: %w", err)
+	}
+
+	if _, err := c.Classes.Parse(); err != nil {
+		return fmt.Errorf(".Classes: %w", err)
+	}
+
 	return nil
 }
 
+func (c *Classes) Parse() (ticker.ClassifierConfig, error) {
+	var out ticker.ClassifierConfig
+	switch c.Priority {
+	case "", "meeting":
+		out.Priority = ticker.ClassMeeting
+	case "focus":
+		out.Priority = ticker.ClassFocus
+	default:
+		return out, fmt.Errorf("invalid priority: %q", c.Priority)
+	}
+
+	for i, r := range c.Focus {
+		rule := ticker.ClassRule{
+			Class:       ticker.ClassFocus,
+			Category:    r.Category,
+			Transparent: r.Transparent,
+		}
+
+		if r.Summary != "" {
+			re, err := regexp.Compile(r.Summary)
+			if err != nil {
+				return out, fmt.Errorf("focus rule %d: invalid summary: %w", i, err)
+			}
+
+			rule.Summary = re
+		}
+
+		if rule.IsZero() {
+			return out, fmt.Errorf("focus rule %d: no conditions", i)
+		}
+
+		out.Rules = append(out.Rules, rule)
+	}
+
+	return out, nil
+}
+
+func (c *WorkingHours) Parse(loc *time.Location) (ticker.WorkingHours, error) {
+	if c.From == "" && c.To == "" {
+		return ticker.WorkingHours{}, nil
+	}
+
+	from, err := parseClock(c.From)
+	if err != nil {
+		return ticker.WorkingHours{}, fmt.Errorf(".From: %w", err)
+	}
+
+	to, err := parseClock(c.To)
+	if err != nil {
+		return ticker.WorkingHours{}, fmt.Errorf(".To: %w", err)
+	}
+
+	// to before from is the overnight shift
+	if from == to {
+		return ticker.WorkingHours{}, fmt.Errorf("invalid range: %s -> %s", c.From, c.To)
+	}
+
+	out := ticker.WorkingHours{
+		From:     from,
+		To:       to,
+		Location: loc,
+	}
+
+	for _, d := range c.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return ticker.WorkingHours{}, fmt.Errorf("invalid day: %q", d)
+		}
+
+		out.Days = append(out.Days, day)
+	}
+
+	return out, nil
+}
+
+// parseClock parses the time of day, e.g. "09:30", into the offset from the midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (r *Runtime) NewMachineConfig() (ticker.MachineConfig, error) {
+	loc, err := time.LoadLocation(r.cfg.Calendar.Timezone)
+	if err != nil {
+		return ticker.MachineConfig{}, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	workingHours, err := r.cfg.Ticker.WorkingHours.Parse(loc)
+	if err != nil {
+		return ticker.MachineConfig{}, fmt.Errorf("invalid working hours: %w", err)
+	}
+
+	return ticker.MachineConfig{
+		UpcomingLimit: r.upcomingLimit(),
+		StartingSoon:  r.cfg.Ticker.StartingSoon,
+		WrappingUp:    r.cfg.Ticker.WrappingUp,
+		StaleAfter:    r.cfg.Ticker.StaleAfter,
+		WorkingHours:  workingHours,
+	}, nil
+}
+
+// startMarks returns the offsets before the interval start the display must be updated at: alerts, upcoming stages and the display wakeup
+func (r *Runtime) startMarks() []time.Duration {
+	stages := r.cfg.Awtrix.Messages.Stages
+	out := append([]time.Duration(nil), r.cfg.Awtrix.Alerts.Offsets...)
+	for _, st := range append(stages.Upcoming, stages.StartingSoon...) {
+		out = append(out, st.Below)
+	}
+
+	if display := r.cfg.Awtrix.Display; display.Enabled() {
+		wakeBefore := display.WakeBefore
+		if wakeBefore <= 0 {
+			wakeBefore = awtrix.DefaultWakeBefore
+		}
+
+		out = append(out, wakeBefore)
+	}
+
+	return out
+}
+
+// endMarks returns the offsets before the interval end the display must be updated at: on-air and focus stages
+func (r *Runtime) endMarks() []time.Duration {
+	stages := r.cfg.Awtrix.Messages.Stages
+	var out []time.Duration
+	for _, sts := range [][]AwtrixStage{stages.OnAir, stages.WrappingUp, stages.Focus} {
+		for _, st := range sts {
+			out = append(out, st.Below)
+		}
+	}
+
+	return out
+}
+
+// upcomingLimit returns the ticker upcoming limit falling back to the legacy awtrix one
+func (r *Runtime) upcomingLimit() time.Duration {
+	if r.cfg.Ticker.UpcomingLimit != 0 {
+		return r.cfg.Ticker.UpcomingLimit
+	}
+
+	return r.cfg.Awtrix.UpcomingLimit
+}
+
 func (r *Runtime) NewTicker() (ticker.Ticker, error) {
 	if err := r.cfg.Calendar.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
@@ -41,5 +245,103 @@ func (r *Runtime) NewTicker() (ticker.Ticker, error) {
 		return nil, fmt.Errorf("create calendar: %w", err)
 	}
 
-	return ticker.NewConstTicker(cal, ticker.ConstTickerConfig(r.cfg.Ticker))
+	states, err := r.NewMachineConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := r.Overrides()
+	if err != nil {
+		return nil, err
+	}
+
+	classifier, err := r.NewClassifier()
+	if err != nil {
+		return nil, err
+	}
+
+	machine := ticker.NewMachine(states).Continue(r.machine)
+	r.machine = machine
+	return ticker.NewConstTicker(cal, ticker.ConstTickerConfig{
+		Jitter:        r.cfg.Ticker.Jitter,
+		PreviewLimit:  r.cfg.Ticker.PreviewLimit,
+		FetchInterval: r.cfg.Ticker.FetchInterval,
+		TickInterval:  r.cfg.Ticker.TickInterval,
+		Marks:         r.startMarks(),
+		EndMarks:      r.endMarks(),
+		Machine:       machine,
+		Overrides:     overrides,
+		Classifier:    classifier,
+	})
+}
+
+func (r *Runtime) NewSimTicker(from, to time.Time, speed float64) (ticker.Ticker, error) {
+	if err := r.cfg.Ticker.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	cal, err := r.NewCalendar()
+	if err != nil {
+		return nil, fmt.Errorf("create calendar: %w", err)
+	}
+
+	states, err := r.NewMachineConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	classifier, err := r.NewClassifier()
+	if err != nil {
+		return nil, err
+	}
+
+	return ticker.NewSimTicker(cal, ticker.SimTickerConfig{
+		Jitter:       r.cfg.Ticker.Jitter,
+		PreviewLimit: r.cfg.Ticker.PreviewLimit,
+		TickInterval: r.cfg.Ticker.TickInterval,
+		From:         from,
+		To:           to,
+		Speed:        speed,
+		Marks:        r.startMarks(),
+		EndMarks:     r.endMarks(),
+		States:       states,
+		Classifier:   classifier,
+	})
+}
+
+func (r *Runtime) Overrides() (*ticker.Overrides, error) {
+	if r.overrides != nil {
+		return r.overrides, nil
+	}
+
+	loc, err := time.LoadLocation(r.cfg.Calendar.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	s, err := r.Storage()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := ticker.NewOverrides(ticker.OverridesConfig{
+		Storage:    s,
+		StorageKey: r.storageKey(ticker.DefaultOverridesStorageKey),
+		Location:   loc,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create overrides: %w", err)
+	}
+
+	r.overrides = overrides
+	return overrides, nil
+}
+
+func (r *Runtime) NewClassifier() (*ticker.Classifier, error) {
+	cfg, err := r.cfg.Ticker.Classes.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid classes: %w", err)
+	}
+
+	return ticker.NewClassifier(cfg)
 }
@@ -0,0 +1,246 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var _ Sink = (*HomeAssistant)(nil)
+
+const (
+	DefaultDiscoveryPrefix = "homeassistant"
+	DefaultNodeID          = "aweeting"
+)
+
+type HomeAssistantDevice struct {
+	Name          string `json:"name,omitempty"`
+	Manufacturer  string `json:"manufacturer,omitempty"`
+	Model         string `json:"model,omitempty"`
+	SuggestedArea string `json:"suggested_area,omitempty"`
+	// Identifiers are filled with the node ID
+	Identifiers []string `json:"identifiers"`
+}
+
+type HomeAssistantConfig struct {
+	// Client must publish its status, which is the availability of the entities
+	Client *broker.Client
+	// Topic is the base of the state topic
+	Topic string
+	// DiscoveryPrefix is the Home Assistant discovery prefix, DefaultDiscoveryPrefix if empty
+	DiscoveryPrefix string
+	// NodeID must be unique per Home Assistant, DefaultNodeID if empty
+	NodeID string
+	Device HomeAssistantDevice
+	// Location of the day boundaries for the meetings remaining today
+	Location *time.Location
+	// Redacted replaces the title of the private meetings
+	Redacted string
+}
+
+// HomeAssistant publishes the Home Assistant MQTT discovery configs and the states of the meeting entities
+type HomeAssistant struct {
+	mqtt *broker.Client
+	cfg  HomeAssistantConfig
+	// status is the connection status topic, online on connect and offline on close or as the last will
+	status     string
+	mu         sync.Mutex
+	discovered bool
+}
+
+type haState struct {
+	OnAir string `json:"on_air"`
+	// Minutes to the next meeting start, null if unknown
+	ToStart *int64 `json:"to_start"`
+	// Minutes to the current meeting end, null if not on-air
+	Left      *int64       `json:"left"`
+	Title     string       `json:"title"`
+	Remaining int          `json:"remaining"`
+	State     ticker.State `json:"state"`
+}
+
+type haEntity struct {
+	component string
+	objectID  string
+	config    map[string]any
+}
+
+func NewHomeAssistant(cfg HomeAssistantConfig) (*HomeAssistant, error) {
+	if cfg.Client == nil {
+		return nil, errors.New(".Client is required")
+	}
+
+	if cfg.Topic == "" {
+		return nil, errors.New(".Topic is required")
+	}
+
+	if cfg.Client.Status() == "" {
+		return nil, errors.New(".Client must publish the status")
+	}
+
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+
+	if cfg.NodeID == "" {
+		cfg.NodeID = DefaultNodeID
+	}
+
+	if cfg.Device.Name == "" {
+		cfg.Device.Name = cfg.NodeID
+	}
+
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+
+	cfg.Device.Identifiers = []string{cfg.NodeID}
+	return &HomeAssistant{
+		mqtt:   cfg.Client,
+		cfg:    cfg,
+		status: cfg.Client.Status(),
+	}, nil
+}
+
+func (h *HomeAssistant) Update(ctx context.Context, event ticker.Event) error {
+	if err := h.discover(ctx, event.Forced); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(h.state(event))
+	if err != nil {
+		return fmt.Errorf("state marshal: %w", err)
+	}
+
+	return h.mqtt.Publish(ctx, h.stateTopic(), true, payload)
+}
+
+// Close does nothing, the entities become unavailable once the connection is closed
+func (h *HomeAssistant) Close(_ context.Context) error {
+	return nil
+}
+
+// discover publishes the discovery configs once, or again if forced
+func (h *HomeAssistant) discover(ctx context.Context, forced bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.discovered && !forced {
+		return nil
+	}
+
+	for _, e := range h.entities() {
+		payload, err := json.Marshal(e.config)
+		if err != nil {
+			return fmt.Errorf("%s config marshal: %w", e.objectID, err)
+		}
+
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", h.cfg.DiscoveryPrefix, e.component, h.cfg.NodeID, e.objectID)
+		if err := h.mqtt.Publish(ctx, topic, true, payload); err != nil {
+			return fmt.Errorf("publish %s config: %w", e.objectID, err)
+		}
+	}
+
+	h.discovered = true
+	return nil
+}
+
+func (h *HomeAssistant) entities() []haEntity {
+	entity := func(component, objectID, name, field string, extra map[string]any) haEntity {
+		cfg := map[string]any{
+			"name":                  name,
+			"unique_id":             h.cfg.NodeID + "_" + objectID,
+			"object_id":             h.cfg.NodeID + "_" + objectID,
+			"state_topic":           h.stateTopic(),
+			"value_template":        "{{ value_json." + field + " }}",
+			"availability_topic":    h.status,
+			"payload_available":     broker.StatusOnline,
+			"payload_not_available": broker.StatusOffline,
+			"device":                h.cfg.Device,
+		}
+		for k, v := range extra {
+			cfg[k] = v
+		}
+
+		return haEntity{
+			component: component,
+			objectID:  objectID,
+			config:    cfg,
+		}
+	}
+
+	return []haEntity{
+		entity("binary_sensor", "on_air", "On air", "on_air", map[string]any{
+			"payload_on":  "ON",
+			"payload_off": "OFF",
+			"icon":        "mdi:video",
+		}),
+		entity("sensor", "to_start", "Next meeting in", "to_start", map[string]any{
+			"unit_of_measurement": "min",
+			"icon":                "mdi:calendar-clock",
+		}),
+		entity("sensor", "left", "Meeting ends in", "left", map[string]any{
+			"unit_of_measurement": "min",
+			"icon":                "mdi:timer-sand",
+		}),
+		entity("sensor", "title", "Meeting", "title", map[string]any{
+			"icon": "mdi:calendar-text",
+		}),
+		entity("sensor", "remaining", "Meetings remaining today", "remaining", map[string]any{
+			"state_class": "measurement",
+			"icon":        "mdi:calendar-multiple",
+		}),
+	}
+}
+
+func (h *HomeAssistant) state(event ticker.Event) haState {
+	out := haState{
+		OnAir: "OFF",
+		State: event.State,
+	}
+
+	minutes := func(d time.Duration) *int64 {
+		m := int64(d / time.Minute)
+		return &m
+	}
+
+	onAir := event.State.Base() == ticker.StateOnAir
+	switch {
+	case event.IsZero():
+	case onAir:
+		out.OnAir = "ON"
+		out.Left = minutes(event.Left)
+		out.Title = event.Summary
+		if event.Private {
+			out.Title = h.cfg.Redacted
+		}
+
+		if !event.Next.IsZero() {
+			out.ToStart = minutes(event.Next.Start.Sub(event.Now))
+		}
+	case event.Upcoming && event.Class == ticker.ClassMeeting:
+		out.ToStart = minutes(event.ToStart)
+	case !event.Next.IsZero():
+		out.ToStart = minutes(event.Next.Start.Sub(event.Now))
+	}
+
+	now := event.Now.In(h.cfg.Location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, h.cfg.Location)
+	for _, i := range event.Intervals {
+		if i.Class == ticker.ClassMeeting && i.Start.After(event.Now) && i.Start.Before(midnight) {
+			out.Remaining++
+		}
+	}
+
+	return out
+}
+
+func (h *HomeAssistant) stateTopic() string {
+	return h.cfg.Topic + "/state"
+}
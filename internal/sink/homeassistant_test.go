@@ -0,0 +1,128 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestHomeAssistant_state(t *testing.T) {
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+	minutes := func(m int64) *int64 {
+		return &m
+	}
+
+	h := &HomeAssistant{
+		cfg: HomeAssistantConfig{
+			Location: time.UTC,
+			Redacted: "Busy",
+		},
+	}
+
+	intervals := []ticker.Interval{
+		{Start: at(10, 0), End: at(10, 30)},
+		{Start: at(11, 0), End: at(12, 0), Class: ticker.ClassFocus},
+		{Start: at(15, 0), End: at(16, 0)},
+		{Start: at(33, 0), End: at(34, 0)},
+	}
+
+	cases := []struct {
+		name     string
+		event    ticker.Event
+		expected haState
+	}{
+		{
+			name: "idle",
+			event: ticker.Event{
+				Now:   at(8, 0),
+				State: ticker.StateIdle,
+			},
+			expected: haState{OnAir: "OFF", State: ticker.StateIdle},
+		},
+		{
+			name: "upcoming",
+			event: ticker.Event{
+				Now:       at(9, 15),
+				State:     ticker.StateUpcoming,
+				Upcoming:  true,
+				StartsAt:  at(10, 0),
+				ToStart:   45 * time.Minute,
+				Summary:   "Daily",
+				Intervals: intervals,
+			},
+			expected: haState{
+				OnAir:     "OFF",
+				ToStart:   minutes(45),
+				Remaining: 2,
+				State:     ticker.StateUpcoming,
+			},
+		},
+		{
+			name: "on-air",
+			event: ticker.Event{
+				Now:       at(10, 10),
+				State:     ticker.StateOnAir,
+				StartsAt:  at(10, 0),
+				Left:      20 * time.Minute,
+				Summary:   "Daily",
+				Next:      intervals[2],
+				Intervals: intervals,
+			},
+			expected: haState{
+				OnAir:     "ON",
+				ToStart:   minutes(290),
+				Left:      minutes(20),
+				Title:     "Daily",
+				Remaining: 1,
+				State:     ticker.StateOnAir,
+			},
+		},
+		{
+			name: "private",
+			event: ticker.Event{
+				Now:      at(10, 10),
+				State:    ticker.StateWrappingUp,
+				StartsAt: at(10, 0),
+				Left:     20 * time.Minute,
+				Summary:  "1:1",
+				Private:  true,
+			},
+			expected: haState{
+				OnAir: "ON",
+				Left:  minutes(20),
+				Title: "Busy",
+				State: ticker.StateWrappingUp,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, h.state(tc.event))
+		})
+	}
+}
+
+func TestHomeAssistant_availability(t *testing.T) {
+	h := &HomeAssistant{
+		cfg: HomeAssistantConfig{
+			Topic:  "aweeting/office",
+			NodeID: "office",
+		},
+		status: "aweeting/status",
+	}
+
+	for _, e := range h.entities() {
+		require.Equal(t, "aweeting/status", e.config["availability_topic"], e.objectID)
+		require.Equal(t, broker.StatusOnline, e.config["payload_available"], e.objectID)
+		require.Equal(t, broker.StatusOffline, e.config["payload_not_available"], e.objectID)
+		require.NotContains(t, e.config, "availability", e.objectID)
+	}
+}
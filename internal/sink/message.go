@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+type Message struct {
+	Now       time.Time    `json:"now"`
+	State     ticker.State `json:"state"`
+	PrevState ticker.State `json:"prevState,omitempty"`
+	StartsAt  *time.Time   `json:"startsAt,omitempty"`
+	EndsAt    *time.Time   `json:"endsAt,omitempty"`
+	// Seconds to the interval start
+	ToStart int64 `json:"toStart,omitempty"`
+	// Seconds to the interval end
+	Left    int64 `json:"left,omitempty"`
+	Snoozed bool  `json:"snoozed,omitempty"`
+}
+
+func NewMessage(event ticker.Event) Message {
+	msg := Message{
+		Now:       event.Now,
+		State:     event.State,
+		PrevState: event.PrevState,
+		Snoozed:   event.Snoozed,
+	}
+
+	if event.IsZero() {
+		return msg
+	}
+
+	if event.Upcoming {
+		msg.ToStart = int64(event.ToStart.Seconds())
+	}
+
+	msg.StartsAt = &event.StartsAt
+	msg.EndsAt = &event.EndsAt
+	msg.Left = int64(event.Left.Seconds())
+	return msg
+}
@@ -0,0 +1,146 @@
+package sink
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+// Registry fans out ticker events to the registered sinks.
+// Each sink is served by its own goroutine, so a slow or failing sink doesn't block the others.
+type Registry struct {
+	mu      sync.Mutex
+	runners []*runner
+	closed  bool
+}
+
+type runner struct {
+	name   string
+	sink   Sink
+	queue  chan ticker.Event
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	log    zerolog.Logger
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(name string, s Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rn := &runner{
+		name:   name,
+		sink:   s,
+		queue:  make(chan ticker.Event, DefaultQueueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+		log:    log.With().Str("sink", name).Logger(),
+	}
+
+	r.runners = append(r.runners, rn)
+	go rn.loop()
+}
+
+// Handle is a ticker.Handler that enqueues the event to each sink and never blocks
+func (r *Registry) Handle(_ context.Context, event ticker.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	for _, rn := range r.runners {
+		rn.enqueue(event)
+	}
+
+	return nil
+}
+
+// Close waits for the queued events to be processed and closes the sinks
+func (r *Registry) Close(ctx context.Context) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+
+	r.closed = true
+	runners := r.runners
+	r.mu.Unlock()
+
+	for _, rn := range runners {
+		close(rn.queue)
+	}
+
+	var wg sync.WaitGroup
+	for _, rn := range runners {
+		wg.Add(1)
+		go func(rn *runner) {
+			defer wg.Done()
+
+			select {
+			case <-rn.done:
+			case <-ctx.Done():
+				rn.cancel()
+				<-rn.done
+			}
+
+			if err := rn.sink.Close(ctx); err != nil {
+				rn.log.Error().Err(err).Msg("close failed")
+			}
+		}(rn)
+	}
+
+	wg.Wait()
+}
+
+func (rn *runner) enqueue(event ticker.Event) {
+	for {
+		select {
+		case rn.queue <- event:
+			return
+		default:
+		}
+
+		// the sink is too slow, drop the oldest event
+		select {
+		case <-rn.queue:
+			rn.log.Warn().Msg("queue is full, event dropped")
+		default:
+		}
+	}
+}
+
+func (rn *runner) loop() {
+	defer close(rn.done)
+	defer rn.cancel()
+
+	for event := range rn.queue {
+		rn.update(event)
+	}
+}
+
+func (rn *runner) update(event ticker.Event) {
+	ctx, cancel := context.WithTimeout(rn.ctx, DefaultUpdateTimeout)
+	defer cancel()
+
+	now := time.Now()
+	if err := rn.sink.Update(ctx, event); err != nil {
+		rn.log.Error().Err(err).Dur("elapsed", time.Since(now)).Msg("update failed")
+		return
+	}
+
+	rn.log.Debug().Dur("elapsed", time.Since(now)).Msg("updated")
+}
@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+type testSink struct {
+	mu     sync.Mutex
+	events []ticker.Event
+	block  chan struct{}
+	err    error
+	closed bool
+}
+
+func (s *testSink) Update(ctx context.Context, event ticker.Event) error {
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func (s *testSink) Close(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	ok := &testSink{}
+	failing := &testSink{err: errors.New("oops")}
+	stuck := &testSink{block: make(chan struct{})}
+
+	reg := NewRegistry()
+	reg.Register("ok", ok)
+	reg.Register("failing", failing)
+	reg.Register("stuck", stuck)
+
+	now := time.Unix(544672800, 0)
+	for i := 0; i < DefaultQueueSize; i++ {
+		require.NoError(t, reg.Handle(context.Background(), ticker.Event{Now: now.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	reg.Close(ctx)
+
+	require.Len(t, ok.events, DefaultQueueSize)
+	require.Len(t, failing.events, DefaultQueueSize)
+	require.Empty(t, stuck.events)
+	for _, s := range []*testSink{ok, failing, stuck} {
+		require.True(t, s.closed)
+	}
+}
+
+func TestRegistry_failing(t *testing.T) {
+	ok := &testSink{}
+	failing := &testSink{err: errors.New("oops")}
+
+	reg := NewRegistry()
+	reg.Register("failing", failing)
+	reg.Register("ok", ok)
+
+	// the failure is only logged by the sink runner, so the ticker keeps going
+	now := time.Unix(544672800, 0)
+	require.NoError(t, reg.Handle(context.Background(), ticker.Event{Now: now}))
+	reg.Close(context.Background())
+
+	require.Len(t, ok.events, 1)
+	require.Len(t, failing.events, 1)
+}
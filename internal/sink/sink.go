@@ -0,0 +1,18 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+const (
+	DefaultUpdateTimeout = 1 * time.Minute
+	DefaultQueueSize     = 8
+)
+
+type Sink interface {
+	Update(ctx context.Context, event ticker.Event) error
+	Close(ctx context.Context) error
+}
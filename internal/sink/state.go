@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/buglloc/aweeting/internal/broker"
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var _ Sink = (*State)(nil)
+
+type StateConfig struct {
+	Client *broker.Client
+	Topic  string
+	Retain bool
+}
+
+// State publishes each event as JSON into the MQTT topic
+type State struct {
+	mqtt   *broker.Client
+	topic  string
+	retain bool
+}
+
+func NewState(cfg StateConfig) (*State, error) {
+	if cfg.Client == nil {
+		return nil, errors.New(".Client is required")
+	}
+
+	if cfg.Topic == "" {
+		return nil, errors.New(".Topic is required")
+	}
+
+	return &State{
+		mqtt:   cfg.Client,
+		topic:  cfg.Topic,
+		retain: cfg.Retain,
+	}, nil
+}
+
+func (s *State) Update(ctx context.Context, event ticker.Event) error {
+	payload, err := json.Marshal(NewMessage(event))
+	if err != nil {
+		return fmt.Errorf("message marshal: %w", err)
+	}
+
+	return s.mqtt.Publish(ctx, s.topic, s.retain, payload)
+}
+
+func (s *State) Close(_ context.Context) error {
+	return nil
+}
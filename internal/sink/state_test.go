@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestNewState(t *testing.T) {
+	_, err := NewState(StateConfig{Topic: "aweeting/state"})
+	require.Error(t, err)
+}
+
+func TestNewMessage(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		event    ticker.Event
+		expected string
+	}{
+		{
+			name: "idle",
+			event: ticker.Event{
+				Now:       now,
+				State:     ticker.StateIdle,
+				PrevState: ticker.StateOnAir,
+				Upcoming:  true,
+			},
+			expected: `{"now":"2026-10-19T10:00:00Z","state":"idle","prevState":"onAir"}`,
+		},
+		{
+			name: "upcoming",
+			event: ticker.Event{
+				Now:      now,
+				State:    ticker.StateStartingSoon,
+				Upcoming: true,
+				ToStart:  90 * time.Second,
+				Left:     31*time.Minute + 30*time.Second,
+				StartsAt: now.Add(90 * time.Second),
+				EndsAt:   now.Add(31*time.Minute + 30*time.Second),
+			},
+			expected: `{"now":"2026-10-19T10:00:00Z","state":"startingSoon","startsAt":"2026-10-19T10:01:30Z","endsAt":"2026-10-19T10:31:30Z","toStart":90,"left":1890}`,
+		},
+		{
+			name: "on-air",
+			event: ticker.Event{
+				Now:      now,
+				State:    ticker.StateWrappingUp,
+				ToStart:  -25 * time.Minute,
+				Left:     5 * time.Minute,
+				StartsAt: now.Add(-25 * time.Minute),
+				EndsAt:   now.Add(5 * time.Minute),
+			},
+			expected: `{"now":"2026-10-19T10:00:00Z","state":"wrappingUp","startsAt":"2026-10-19T09:35:00Z","endsAt":"2026-10-19T10:05:00Z","left":300}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := json.Marshal(NewMessage(tc.event))
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(actual))
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+var _ Sink = (*Stdout)(nil)
+
+// Stdout writes each event as a JSON line
+type Stdout struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdout(w io.Writer) *Stdout {
+	return &Stdout{
+		w: w,
+	}
+}
+
+func (s *Stdout) Update(_ context.Context, event ticker.Event) error {
+	payload, err := json.Marshal(NewMessage(event))
+	if err != nil {
+		return fmt.Errorf("message marshal: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = fmt.Fprintf(s.w, "%s\n", payload)
+	return err
+}
+
+func (s *Stdout) Close(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+func TestStdout(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	var out bytes.Buffer
+	s := NewStdout(&out)
+	require.NoError(t, s.Update(context.Background(), ticker.Event{
+		Now:   now,
+		State: ticker.StateIdle,
+	}))
+	require.NoError(t, s.Update(context.Background(), ticker.Event{
+		Now:       now.Add(time.Minute),
+		State:     ticker.StateOnAir,
+		PrevState: ticker.StateUpcoming,
+		Left:      29 * time.Minute,
+		StartsAt:  now.Add(time.Minute),
+		EndsAt:    now.Add(30 * time.Minute),
+		Snoozed:   true,
+	}))
+
+	require.Equal(t,
+		`{"now":"2026-10-19T10:00:00Z","state":"idle"}`+"\n"+
+			`{"now":"2026-10-19T10:01:00Z","state":"onAir","prevState":"upcoming","startsAt":"2026-10-19T10:01:00Z","endsAt":"2026-10-19T10:30:00Z","left":1740,"snoozed":true}`+"\n",
+		out.String(),
+	)
+}
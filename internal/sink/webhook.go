@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+const DefaultWebhookTimeout = 10 * time.Second
+
+var _ Sink = (*Webhook)(nil)
+
+type WebhookConfig struct {
+	URL     string
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+// Webhook POSTs each event as JSON to the URL
+type Webhook struct {
+	url   string
+	httpc *resty.Client
+}
+
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, errors.New(".URL is required")
+	}
+
+	timeout := DefaultWebhookTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+
+	return &Webhook{
+		url: cfg.URL,
+		httpc: resty.New().
+			SetTimeout(timeout).
+			SetHeaders(cfg.Headers).
+			SetRetryCount(3).
+			SetRetryWaitTime(100 * time.Millisecond).
+			SetRetryMaxWaitTime(5 * time.Second).
+			AddRetryCondition(func(rsp *resty.Response, err error) bool {
+				return err != nil || rsp.StatusCode() >= http.StatusInternalServerError
+			}),
+	}, nil
+}
+
+func (s *Webhook) Update(ctx context.Context, event ticker.Event) error {
+	rsp, err := s.httpc.R().
+		SetContext(ctx).
+		SetBody(NewMessage(event)).
+		Post(s.url)
+	if err != nil {
+		return fmt.Errorf("send webhook: %w", err)
+	}
+
+	if rsp.IsError() {
+		return fmt.Errorf("non-2xx response: %s", rsp.Status())
+	}
+
+	return nil
+}
+
+func (s *Webhook) Close(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,117 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/ticker"
+)
+
+// webhookStub is the stand-in of the webhook receiver failing the first failures requests
+type webhookStub struct {
+	mu       sync.Mutex
+	status   int
+	failures int
+	messages []Message
+}
+
+func (s *webhookStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(s.status)
+		return
+	}
+
+	var msg Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.messages = append(s.messages, msg)
+}
+
+func (s *webhookStub) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := s.messages
+	s.messages = nil
+	return out
+}
+
+func TestWebhook(t *testing.T) {
+	stub := &webhookStub{status: http.StatusBadGateway, failures: 2}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	s, err := NewWebhook(WebhookConfig{
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2026, 10, 19, 9, 50, 0, 0, time.UTC)
+	startsAt := now.Add(10 * time.Minute)
+	endsAt := now.Add(40 * time.Minute)
+	event := ticker.Event{
+		Now:       now,
+		State:     ticker.StateUpcoming,
+		PrevState: ticker.StateIdle,
+		Upcoming:  true,
+		ToStart:   10 * time.Minute,
+		Left:      40 * time.Minute,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+	}
+
+	// the server errors are retried
+	require.NoError(t, s.Update(context.Background(), event))
+	require.Equal(t, []Message{
+		{
+			Now:       now,
+			State:     ticker.StateUpcoming,
+			PrevState: ticker.StateIdle,
+			StartsAt:  &startsAt,
+			EndsAt:    &endsAt,
+			ToStart:   600,
+			Left:      2400,
+		},
+	}, stub.Messages())
+}
+
+func TestWebhook_errors(t *testing.T) {
+	stub := &webhookStub{status: http.StatusInternalServerError, failures: 10}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	_, err := NewWebhook(WebhookConfig{})
+	require.Error(t, err)
+
+	unauthorized, err := NewWebhook(WebhookConfig{URL: srv.URL})
+	require.NoError(t, err)
+	require.ErrorContains(t, unauthorized.Update(context.Background(), ticker.Event{}), "403")
+
+	failing, err := NewWebhook(WebhookConfig{
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+	require.ErrorContains(t, failing.Update(context.Background(), ticker.Event{}), "500")
+	require.Empty(t, stub.Messages())
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage is a tiny JSON file backed key-value storage to keep state across restarts.
+// With empty path it works in memory only.
+type Storage struct {
+	mu   sync.Mutex
+	path string
+	data map[string]json.RawMessage
+}
+
+func NewStorage(path string) (*Storage, error) {
+	s := &Storage{
+		path: path,
+		data: make(map[string]json.RawMessage),
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+
+		return nil, fmt.Errorf("read storage: %w", err)
+	}
+
+	if len(raw) == 0 {
+		return s, nil
+	}
+
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return nil, fmt.Errorf("parse storage %q: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s *Storage) Get(key string, v any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.data[key]
+	if !ok {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, fmt.Errorf("unmarshal %q: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (s *Storage) Set(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal %q: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = raw
+	return s.flush()
+}
+
+func (s *Storage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data[key]; !ok {
+		return nil
+	}
+
+	delete(s.data, key)
+	return s.flush()
+}
+
+func (s *Storage) flush() error {
+	if s.path == "" {
+		return nil
+	}
+
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return fmt.Errorf("marshal storage: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write storage: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close storage: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("save storage: %w", err)
+	}
+
+	return nil
+}
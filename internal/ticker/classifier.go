@@ -0,0 +1,124 @@
+package ticker
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/calendar"
+)
+
+// Class of the calendar event, meetings and other classes are merged into intervals separately
+type Class string
+
+const (
+	// ClassMeeting is the default class of the events
+	ClassMeeting Class = ""
+	// ClassFocus is the focus time block, produces the focus state while in progress
+	ClassFocus Class = "focus"
+)
+
+// ClassRule matches the event if all of its non-empty conditions match
+type ClassRule struct {
+	Class       Class
+	Summary     *regexp.Regexp
+	Category    string
+	Transparent bool
+}
+
+func (r *ClassRule) IsZero() bool {
+	return r.Summary == nil && r.Category == "" && !r.Transparent
+}
+
+func (r *ClassRule) Match(e calendar.Event) bool {
+	if r.Summary != nil && !r.Summary.MatchString(e.Summary) {
+		return false
+	}
+
+	if r.Transparent && !e.Transparent {
+		return false
+	}
+
+	if r.Category == "" {
+		return true
+	}
+
+	for _, c := range e.Categories {
+		if strings.EqualFold(c, r.Category) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ClassifierConfig struct {
+	// Rules are checked in order, the first matched one wins
+	Rules []ClassRule
+	// Priority is the class winning when its interval overlaps the other class one, meetings by default
+	Priority Class
+}
+
+// Classifier assigns classes to the calendar events and picks the winning interval of the overlapping ones
+type Classifier struct {
+	rules    []ClassRule
+	priority Class
+}
+
+func NewClassifier(cfg ClassifierConfig) (*Classifier, error) {
+	for _, r := range cfg.Rules {
+		if r.IsZero() {
+			return nil, errors.New("empty class rule")
+		}
+	}
+
+	return &Classifier{
+		rules:    cfg.Rules,
+		priority: cfg.Priority,
+	}, nil
+}
+
+func (c *Classifier) Classify(e calendar.Event) Class {
+	if c == nil {
+		return ClassMeeting
+	}
+
+	for _, r := range c.rules {
+		if r.Match(e) {
+			return r.Class
+		}
+	}
+
+	return ClassMeeting
+}
+
+// Pick returns the interval to be shown out of the current intervals of each class.
+// Intervals in progress win over upcoming ones, then the priority class wins.
+// Only meetings are shown as upcoming, since there is nothing to prepare for the upcoming focus block.
+func (c *Classifier) Pick(intervals []Interval, now time.Time) Interval {
+	var priority Class
+	if c != nil {
+		priority = c.priority
+	}
+
+	var inProgress, upcoming Interval
+	for _, i := range intervals {
+		if i.Start.After(now) {
+			if i.Class == ClassMeeting {
+				upcoming = i
+			}
+			continue
+		}
+
+		if inProgress.IsZero() || i.Class == priority {
+			inProgress = i
+		}
+	}
+
+	if !inProgress.IsZero() {
+		return inProgress
+	}
+
+	return upcoming
+}
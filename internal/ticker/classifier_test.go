@@ -0,0 +1,160 @@
+package ticker
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/calendar"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	cls, err := NewClassifier(ClassifierConfig{
+		Rules: []ClassRule{
+			{
+				Class:   ClassFocus,
+				Summary: regexp.MustCompile(`(?i)^focus`),
+			},
+			{
+				Class:       ClassFocus,
+				Category:    "deep work",
+				Transparent: true,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		event    calendar.Event
+		expected Class
+	}{
+		{
+			name:     "meeting",
+			event:    calendar.Event{Summary: "Daily"},
+			expected: ClassMeeting,
+		},
+		{
+			name:     "summary",
+			event:    calendar.Event{Summary: "Focus: review"},
+			expected: ClassFocus,
+		},
+		{
+			name: "category",
+			event: calendar.Event{
+				Categories:  []string{"Deep Work"},
+				Transparent: true,
+			},
+			expected: ClassFocus,
+		},
+		{
+			name: "category-opaque",
+			event: calendar.Event{
+				Categories: []string{"Deep Work"},
+			},
+			expected: ClassMeeting,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, cls.Classify(tc.event))
+		})
+	}
+
+	_, err = NewClassifier(ClassifierConfig{Rules: []ClassRule{{Class: ClassFocus}}})
+	require.Error(t, err)
+}
+
+func TestIntervaler_classes(t *testing.T) {
+	focus := calendar.Event{
+		ID:      1,
+		Summary: "Focus",
+		Start:   now.Add(-time.Hour),
+		End:     now.Add(2 * time.Hour),
+	}
+	meeting := calendar.Event{
+		ID:      2,
+		Summary: "Sync",
+		Start:   now.Add(30 * time.Minute),
+		End:     now.Add(time.Hour),
+	}
+
+	cases := []struct {
+		name     string
+		priority Class
+		now      time.Time
+		expected Interval
+	}{
+		{
+			name: "focus",
+			now:  now,
+			expected: Interval{
+				Start:   focus.Start,
+				End:     focus.End,
+				Class:   ClassFocus,
+				Summary: focus.Summary,
+			},
+		},
+		{
+			name: "meeting-wins",
+			now:  meeting.Start,
+			expected: Interval{
+				Start:   meeting.Start,
+				End:     meeting.End,
+				Summary: meeting.Summary,
+			},
+		},
+		{
+			name: "focus-again",
+			now:  meeting.End,
+			expected: Interval{
+				Start:   focus.Start,
+				End:     focus.End,
+				Class:   ClassFocus,
+				Summary: focus.Summary,
+			},
+		},
+		{
+			name:     "focus-wins",
+			priority: ClassFocus,
+			now:      meeting.Start,
+			expected: Interval{
+				Start:   focus.Start,
+				End:     focus.End,
+				Class:   ClassFocus,
+				Summary: focus.Summary,
+			},
+		},
+		{
+			name: "upcoming-meeting",
+			now:  focus.Start.Add(-time.Hour),
+			expected: Interval{
+				Start:   meeting.Start,
+				End:     meeting.End,
+				Summary: meeting.Summary,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cls, err := NewClassifier(ClassifierConfig{
+				Rules: []ClassRule{
+					{
+						Class:   ClassFocus,
+						Summary: regexp.MustCompile(`^Focus$`),
+					},
+				},
+				Priority: tc.priority,
+			})
+			require.NoError(t, err)
+
+			i := NewIntervaler(0).WithClassifier(cls)
+			i.UpdateEvents([]calendar.Event{focus, meeting})
+			require.Equal(t, tc.expected, i.CurrentAt(tc.now))
+		})
+	}
+}
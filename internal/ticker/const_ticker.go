@@ -2,7 +2,10 @@ package ticker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -24,6 +27,17 @@ type ConstTickerConfig struct {
 	PreviewLimit  time.Duration
 	FetchInterval time.Duration
 	TickInterval  time.Duration
+	// Marks are offsets before the interval start when an extra tick must be fired
+	Marks []time.Duration
+	// EndMarks are offsets before the interval end when an extra tick must be fired
+	EndMarks []time.Duration
+	States   MachineConfig
+	// Machine derives the states, e.g. continuing the replaced ticker one, created from States if nil
+	Machine *Machine
+	// Overrides are the manual intervals merged with the calendar events, optional
+	Overrides *Overrides
+	// Classifier assigns the event classes, all events are meetings if nil
+	Classifier *Classifier
 }
 
 type ConstTicker struct {
@@ -32,40 +46,69 @@ type ConstTicker struct {
 	cancelCtx     context.CancelFunc
 	done          chan struct{}
 	interval      *Intervaler
+	machine       *Machine
+	fetchedAt     atomic.Int64
 	previewLimit  time.Duration
 	fetchInterval time.Duration
 	tickInterval  time.Duration
+	marks         []time.Duration
+	endMarks      []time.Duration
+	overrides     *Overrides
+	handler       Handler
+	tickMu        sync.Mutex
+	wakeMu        sync.Mutex
+	wakeup        *time.Timer
 }
 
 func NewConstTicker(cal calendar.Calendar, cfg ConstTickerConfig) (*ConstTicker, error) {
+	machine := cfg.Machine
+	if machine == nil {
+		machine = NewMachine(cfg.States)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	return &ConstTicker{
 		cal:           cal,
 		ctx:           ctx,
 		cancelCtx:     cancel,
 		done:          make(chan struct{}),
-		interval:      NewIntervaler(cfg.Jitter),
+		interval:      NewIntervaler(cfg.Jitter).WithOverrides(cfg.Overrides).WithClassifier(cfg.Classifier),
+		machine:       machine,
 		previewLimit:  cfg.PreviewLimit,
 		fetchInterval: cfg.FetchInterval,
 		tickInterval:  cfg.TickInterval,
+		marks:         cfg.Marks,
+		endMarks:      cfg.EndMarks,
+		overrides:     cfg.Overrides,
 	}, nil
 }
 
 func (t *ConstTicker) Start(handler Handler) error {
 	defer close(t.done)
 
+	t.tickMu.Lock()
+	t.handler = handler
+	t.tickMu.Unlock()
+
 	if err := t.fetchEvents(t.ctx); err != nil {
 		return fmt.Errorf("first update events: %w", err)
 	}
 
-	handle := t.newTickHandle(handler)
-	if err := handle(t.ctx); err != nil {
+	if err := t.tick(t.ctx); err != nil {
 		return fmt.Errorf("first tick: %w", err)
 	}
 
 	log.Info().Msg("const ticker started")
 	NewTimer(
-		t.fetchEvents,
+		func(ctx context.Context) error {
+			if err := t.fetchEvents(ctx); err != nil {
+				return err
+			}
+
+			now := nowFn()
+			t.scheduleWakeup(t.interval.CurrentAt(now), now)
+			return nil
+		},
 		TimerConfig{
 			Name:     "fetch",
 			Interval: t.fetchInterval,
@@ -73,7 +116,7 @@ func (t *ConstTicker) Start(handler Handler) error {
 	).Start(t.ctx)
 
 	NewTimer(
-		handle,
+		t.tick,
 		TimerConfig{
 			Name:     "tick",
 			Interval: t.tickInterval,
@@ -86,6 +129,14 @@ func (t *ConstTicker) Start(handler Handler) error {
 
 func (t *ConstTicker) Stop(ctx context.Context) {
 	t.cancelCtx()
+
+	t.wakeMu.Lock()
+	if t.wakeup != nil {
+		t.wakeup.Stop()
+		t.wakeup = nil
+	}
+	t.wakeMu.Unlock()
+
 	select {
 	case <-ctx.Done():
 		return
@@ -94,6 +145,35 @@ func (t *ConstTicker) Stop(ctx context.Context) {
 	}
 }
 
+func (t *ConstTicker) Tick(ctx context.Context) error {
+	return t.tick(ctx)
+}
+
+func (t *ConstTicker) Republish(ctx context.Context) error {
+	return t.tickAt(ctx, nowFn().Truncate(time.Minute), true)
+}
+
+func (t *ConstTicker) Refresh(ctx context.Context) error {
+	if err := t.fetchEvents(ctx); err != nil {
+		return err
+	}
+
+	return t.tick(ctx)
+}
+
+func (t *ConstTicker) Execute(ctx context.Context, cmd Command) error {
+	if cmd.Action == CommandRefresh {
+		return t.Refresh(ctx)
+	}
+
+	// ticks are minute aligned, so align the overrides too to not see "busy now" as upcoming
+	if err := t.interval.Apply(cmd, nowFn().Truncate(time.Minute)); err != nil {
+		return fmt.Errorf("apply command: %w", err)
+	}
+
+	return t.tick(ctx)
+}
+
 func (t *ConstTicker) fetchEvents(ctx context.Context) error {
 	events, err := t.cal.Events(ctx, t.previewLimit)
 	if err != nil {
@@ -102,12 +182,113 @@ func (t *ConstTicker) fetchEvents(ctx context.Context) error {
 	log.Ctx(ctx).Info().Int("count", len(events)).Msg("got calendar events")
 
 	t.interval.UpdateEvents(events)
+	t.fetchedAt.Store(nowFn().UnixNano())
 	return nil
 }
 
-func (t *ConstTicker) newTickHandle(handler Handler) func(ctx context.Context) error {
-	return func(ctx context.Context) error {
-		event := t.interval.Current().ToEvent(time.Now().Truncate(time.Minute))
-		return handler(ctx, event)
+func (t *ConstTicker) tick(ctx context.Context) error {
+	return t.tickAt(ctx, nowFn().Truncate(time.Minute), false)
+}
+
+func (t *ConstTicker) tickAt(ctx context.Context, now time.Time, forced bool) error {
+	t.tickMu.Lock()
+	defer t.tickMu.Unlock()
+
+	if t.handler == nil {
+		return errors.New("ticker is not started")
+	}
+
+	// manual commands run outside the app lock and may race with the stop
+	if t.ctx.Err() != nil {
+		return errors.New("ticker is stopped")
+	}
+
+	cur, next := t.interval.CurrentWithNextAt(now)
+	defer t.scheduleWakeup(cur, now)
+
+	event := cur.ToEvent(now)
+	event.Next = next
+	event.Intervals = t.interval.IntervalsAt(now)
+	event = t.machine.Next(event, t.lastFetch())
+	event.Forced = forced
+	if t.overrides != nil {
+		_, event.Snoozed = t.overrides.SnoozedUntil(now)
+	}
+
+	return t.handler(ctx, event)
+}
+
+func (t *ConstTicker) lastFetch() time.Time {
+	return time.Unix(0, t.fetchedAt.Load())
+}
+
+// scheduleWakeup arms an extra tick for the nearest state transition happening before the next regular tick
+func (t *ConstTicker) scheduleWakeup(cur Interval, now time.Time) {
+	t.wakeMu.Lock()
+	defer t.wakeMu.Unlock()
+
+	if t.wakeup != nil {
+		t.wakeup.Stop()
+		t.wakeup = nil
+	}
+
+	// the ticker is stopped
+	if t.ctx.Err() != nil {
+		return
+	}
+
+	next := now.Add(t.tickInterval).Truncate(t.tickInterval)
+	var at time.Time
+	for _, ts := range t.transitions(cur, now) {
+		if !ts.After(now) || !ts.Before(next) {
+			continue
+		}
+
+		if at.IsZero() || ts.Before(at) {
+			at = ts
+		}
+	}
+
+	if at.IsZero() {
+		return
+	}
+
+	t.wakeup = time.AfterFunc(at.Sub(nowFn()), func() {
+		if t.ctx.Err() != nil {
+			return
+		}
+
+		ctx := log.With().Str("name", "wakeup").Logger().WithContext(t.ctx)
+		if err := t.tickAt(ctx, at, false); err != nil {
+			log.Ctx(ctx).Err(err).Time("at", at).Msg("wakeup tick failed")
+		}
+	})
+}
+
+func (t *ConstTicker) transitions(cur Interval, now time.Time) []time.Time {
+	out := append(t.machine.Transitions(cur), t.machine.NextTransition(now, t.lastFetch()))
+	if t.overrides != nil {
+		if until, ok := t.overrides.SnoozedUntil(now); ok {
+			out = append(out, until)
+		}
+	}
+
+	// the other class interval may take over the current one
+	for _, chain := range t.interval.ChainsAt(now) {
+		out = append(out, chain.Start, chain.End)
 	}
+
+	if cur.IsZero() {
+		return out
+	}
+
+	for _, m := range t.marks {
+		out = append(out, cur.Start.Add(-m))
+	}
+
+	for _, m := range t.endMarks {
+		out = append(out, cur.End.Add(-m))
+	}
+
+	return out
 }
@@ -1,7 +1,9 @@
 package ticker
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -9,14 +11,32 @@ import (
 )
 
 type Intervaler struct {
-	mu     sync.RWMutex
-	events []calendar.Event
-	jitter time.Duration
+	mu         sync.RWMutex
+	events     []calendar.Event
+	jitter     time.Duration
+	overrides  *Overrides
+	classifier *Classifier
 }
 
 type Interval struct {
-	Start time.Time
-	End   time.Time
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Class Class     `json:"class,omitempty"`
+	// Summary and Location of the event in progress, or of the first one for the upcoming interval
+	Summary  string `json:"summary,omitempty"`
+	Location string `json:"location,omitempty"`
+	// Private means the summary and location must not be shown
+	Private bool `json:"private,omitempty"`
+}
+
+func newInterval(e calendar.Event) Interval {
+	return Interval{
+		Start:    e.Start,
+		End:      e.End,
+		Summary:  e.Summary,
+		Location: e.Location,
+		Private:  e.Private,
+	}
 }
 
 func NewIntervaler(jitter time.Duration) *Intervaler {
@@ -25,6 +45,76 @@ func NewIntervaler(jitter time.Duration) *Intervaler {
 	}
 }
 
+// WithClassifier merges the events of each class separately
+func (c *Intervaler) WithClassifier(cls *Classifier) *Intervaler {
+	c.classifier = cls
+	return c
+}
+
+// WithOverrides merges the manual overrides into the calendar events
+func (c *Intervaler) WithOverrides(o *Overrides) *Intervaler {
+	c.overrides = o
+	return c
+}
+
+// Apply applies the manual command at the given moment, picking the events the command ends
+func (c *Intervaler) Apply(cmd Command, now time.Time) error {
+	if c.overrides == nil {
+		return errors.New("manual overrides are not configured")
+	}
+
+	var targets []calendar.Event
+	switch cmd.Action {
+	case CommandFree:
+		targets = c.eventsAt(now)
+	case CommandDismiss:
+		cur := c.CurrentAt(now)
+		if cur.IsZero() {
+			return errors.New("nothing to dismiss")
+		}
+
+		targets = c.eventsOf(cur, now)
+	}
+
+	return c.overrides.Apply(cmd, now, targets)
+}
+
+// eventsOf returns the events merged with the overrides, which form the given interval
+func (c *Intervaler) eventsOf(i Interval, now time.Time) []calendar.Event {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, byClass := c.classify()
+
+	var out []calendar.Event
+	for _, e := range byClass[i.Class] {
+		if e.End.After(now) && e.End.After(i.Start) && e.Start.Before(i.End) {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+// eventsAt returns the events merged with the overrides, which are in progress at the given moment
+func (c *Intervaler) eventsAt(now time.Time) []calendar.Event {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, byClass := c.classify()
+
+	var out []calendar.Event
+	for _, events := range byClass {
+		for _, e := range events {
+			if !e.Start.After(now) && e.End.After(now) {
+				out = append(out, e)
+			}
+		}
+	}
+
+	return out
+}
+
 func (c *Intervaler) UpdateEvents(events []calendar.Event) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -33,43 +123,179 @@ func (c *Intervaler) UpdateEvents(events []calendar.Event) {
 }
 
 func (c *Intervaler) Current() Interval {
+	return c.CurrentAt(nowFn())
+}
+
+func (c *Intervaler) CurrentAt(now time.Time) Interval {
+	cur, _ := c.CurrentWithNextAt(now)
+	return cur
+}
+
+// CurrentWithNextAt returns the interval to be shown and the next meeting interval starting after it
+func (c *Intervaler) CurrentWithNextAt(now time.Time) (Interval, Interval) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chains, nexts := c.chainsAt(now)
+	cur := c.classifier.Pick(chains, now)
+	if cur.IsZero() {
+		return cur, Interval{}
+	}
+
+	var next Interval
+	for _, i := range append(chains, nexts...) {
+		if i.Class != ClassMeeting || !i.Start.After(cur.Start) {
+			continue
+		}
+
+		if next.IsZero() || i.Start.Before(next.Start) {
+			next = i
+		}
+	}
+
+	return cur, next
+}
+
+// ChainsAt returns the current interval of each class, both in progress and upcoming ones
+func (c *Intervaler) ChainsAt(now time.Time) []Interval {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chains, _ := c.chainsAt(now)
+	return chains
+}
+
+// chainsAt returns the current interval of each class and the intervals following them
+func (c *Intervaler) chainsAt(now time.Time) ([]Interval, []Interval) {
+	classes, byClass := c.classify()
+
+	var out, nexts []Interval
+	for _, class := range classes {
+		cur, next := c.chainAt(byClass[class], now)
+		if cur.IsZero() {
+			continue
+		}
+
+		cur.Class = class
+		out = append(out, cur)
+		if !next.IsZero() {
+			next.Class = class
+			nexts = append(nexts, next)
+		}
+	}
+
+	// drop the expired events only, the masked ones come back once the overrides are cleared
+	for len(c.events) > 0 && !c.events[0].End.After(now) {
+		c.events = c.events[1:]
+	}
+
+	return out, nexts
+}
+
+// IntervalsAt returns the merged intervals of all classes ending after the given moment, sorted by start
+func (c *Intervaler) IntervalsAt(now time.Time) []Interval {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	skip := 0
-	now := nowFn()
-	for _, e := range c.events {
-		if now.Before(e.End) {
-			break
+	classes, byClass := c.classify()
+
+	var out []Interval
+	for _, class := range classes {
+		var cur Interval
+		for _, e := range byClass[class] {
+			if !now.Before(e.End) {
+				continue
+			}
+
+			if !cur.IsZero() && cur.merge(e, c.jitter, now) {
+				continue
+			}
+
+			if cur.End.After(now) {
+				out = append(out, cur)
+			}
+
+			cur = newInterval(e)
+			cur.Class = class
+		}
+
+		if cur.End.After(now) {
+			out = append(out, cur)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Start.Before(out[j].Start)
+	})
+
+	return out
+}
+
+// classify groups the events merged with the overrides by their class, keeping the order of the first class appearance
+func (c *Intervaler) classify() ([]Class, map[Class][]calendar.Event) {
+	events := c.events
+	if c.overrides != nil {
+		events = c.overrides.Merge(events)
+	}
+
+	var classes []Class
+	byClass := make(map[Class][]calendar.Event)
+	for _, e := range events {
+		class := c.classifier.Classify(e)
+		if _, ok := byClass[class]; !ok {
+			classes = append(classes, class)
 		}
 
-		skip++
+		byClass[class] = append(byClass[class], e)
 	}
-	c.events = c.events[skip:]
 
-	if len(c.events) == 0 {
-		return Interval{}
+	return classes, byClass
+}
+
+// chainAt returns the chain of the sorted events merged with jitter, which is in progress or upcoming at the given moment,
+// and the chain following it. The expired events are dropped before merging.
+func (c *Intervaler) chainAt(events []calendar.Event, now time.Time) (Interval, Interval) {
+	for len(events) > 0 && !now.Before(events[0].End) {
+		events = events[1:]
 	}
 
-	cur := Interval{
-		Start: c.events[0].Start,
-		End:   c.events[0].End,
+	if len(events) == 0 {
+		return Interval{}, Interval{}
 	}
 
-	for _, e := range c.events[1:] {
+	var next Interval
+	cur := newInterval(events[0])
+	for _, e := range events[1:] {
 		switch {
-		case cur.Start.Before(e.Start) && cur.End.After(e.End):
-			// overlap
-			continue
-		case cur.End.Add(c.jitter).After(e.Start):
-			cur.End = e.End
-			continue
+		case next.IsZero() && cur.merge(e, c.jitter, now):
+		case next.IsZero():
+			next = newInterval(e)
+		case !next.merge(e, c.jitter, now):
+			return cur, next
 		}
+	}
 
-		break
+	return cur, next
+}
+
+// merge extends the interval with the overlapping event or the one starting within jitter after its end
+func (i *Interval) merge(e calendar.Event, jitter time.Duration, now time.Time) bool {
+	switch {
+	case i.Start.Before(e.Start) && i.End.After(e.End):
+		// overlap
+	case i.End.Add(jitter).After(e.Start):
+		i.End = e.End
+	default:
+		return false
 	}
 
-	return cur
+	if !e.Start.After(now) && e.End.After(now) {
+		i.Summary = e.Summary
+		i.Location = e.Location
+		i.Private = e.Private
+	}
+
+	return true
 }
 
 func (i Interval) String() string {
@@ -83,15 +309,21 @@ func (i Interval) IsZero() bool {
 func (i Interval) ToEvent(now time.Time) Event {
 	if i.Start.IsZero() {
 		return Event{
+			Now:      now,
 			Upcoming: true,
 		}
 	}
 
 	return Event{
+		Now:      now,
 		Upcoming: i.Start.After(now),
 		ToStart:  i.Start.Sub(now),
 		Left:     i.End.Sub(now),
 		StartsAt: i.Start,
 		EndsAt:   i.End,
+		Class:    i.Class,
+		Summary:  i.Summary,
+		Location: i.Location,
+		Private:  i.Private,
 	}
 }
@@ -220,6 +220,26 @@ func TestIntervaler_merge(t *testing.T) {
 				End:   now.Add(60 * time.Minute),
 			},
 		},
+		{
+			name:   "expired-not-merged",
+			jitter: 20 * time.Minute,
+			events: []calendar.Event{
+				{
+					ID:    1,
+					Start: now.Add(-30 * time.Minute),
+					End:   now.Add(-10 * time.Minute),
+				},
+				{
+					ID:    2,
+					Start: now.Add(5 * time.Minute),
+					End:   now.Add(60 * time.Minute),
+				},
+			},
+			expected: Interval{
+				Start: now.Add(5 * time.Minute),
+				End:   now.Add(60 * time.Minute),
+			},
+		},
 		{
 			name:   "merge-w-jitter",
 			jitter: 1 * time.Minute,
@@ -252,3 +272,107 @@ func TestIntervaler_merge(t *testing.T) {
 		})
 	}
 }
+
+func TestIntervaler_next(t *testing.T) {
+	events := []calendar.Event{
+		{
+			ID:       1,
+			Summary:  "Daily",
+			Location: "Room 1",
+			Start:    now.Add(-30 * time.Minute),
+			End:      now.Add(-10 * time.Minute),
+		},
+		{
+			ID:       2,
+			Summary:  "Review",
+			Location: "Room 2",
+			Start:    now.Add(-15 * time.Minute),
+			End:      now.Add(10 * time.Minute),
+		},
+		{
+			ID:      3,
+			Summary: "Planning",
+			Start:   now.Add(40 * time.Minute),
+			End:     now.Add(60 * time.Minute),
+		},
+		{
+			ID:      4,
+			Summary: "Retro",
+			Start:   now.Add(65 * time.Minute),
+			End:     now.Add(90 * time.Minute),
+		},
+		{
+			ID:      5,
+			Summary: "1:1",
+			Start:   now.Add(3 * time.Hour),
+			End:     now.Add(4 * time.Hour),
+		},
+	}
+
+	i := NewIntervaler(10 * time.Minute)
+	i.UpdateEvents(events)
+
+	cur, next := i.CurrentWithNextAt(now)
+	require.Equal(t, Interval{
+		Start:    now.Add(-15 * time.Minute),
+		End:      now.Add(10 * time.Minute),
+		Summary:  "Review",
+		Location: "Room 2",
+	}, cur)
+	require.Equal(t, Interval{
+		Start:   now.Add(40 * time.Minute),
+		End:     now.Add(90 * time.Minute),
+		Summary: "Planning",
+	}, next)
+
+	cur, next = i.CurrentWithNextAt(now.Add(2 * time.Hour))
+	require.Equal(t, "1:1", cur.Summary)
+	require.True(t, next.IsZero())
+}
+
+func TestIntervaler_intervals(t *testing.T) {
+	events := []calendar.Event{
+		{
+			ID:      1,
+			Summary: "Daily",
+			Start:   now.Add(-2 * time.Hour),
+			End:     now.Add(-1 * time.Hour),
+		},
+		{
+			ID:      2,
+			Summary: "Review",
+			Start:   now.Add(-15 * time.Minute),
+			End:     now.Add(10 * time.Minute),
+		},
+		{
+			ID:      3,
+			Summary: "Planning",
+			Start:   now.Add(15 * time.Minute),
+			End:     now.Add(30 * time.Minute),
+		},
+		{
+			ID:      4,
+			Summary: "1:1",
+			Start:   now.Add(3 * time.Hour),
+			End:     now.Add(4 * time.Hour),
+		},
+	}
+
+	i := NewIntervaler(10 * time.Minute)
+	i.UpdateEvents(events)
+
+	require.Equal(t, []Interval{
+		{
+			Start:   now.Add(-15 * time.Minute),
+			End:     now.Add(30 * time.Minute),
+			Class:   ClassMeeting,
+			Summary: "Review",
+		},
+		{
+			Start:   now.Add(3 * time.Hour),
+			End:     now.Add(4 * time.Hour),
+			Class:   ClassMeeting,
+			Summary: "1:1",
+		},
+	}, i.IntervalsAt(now))
+}
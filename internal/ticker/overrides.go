@@ -0,0 +1,316 @@
+package ticker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buglloc/aweeting/internal/calendar"
+	"github.com/buglloc/aweeting/internal/storage"
+)
+
+const DefaultOverridesStorageKey = "ticker.overrides"
+
+type CommandAction string
+
+const (
+	// CommandBusy adds the manual busy interval starting now
+	CommandBusy CommandAction = "busy"
+	// CommandFree ends the meetings in progress
+	CommandFree CommandAction = "free"
+	// CommandDismiss ends the current meeting interval, or skips the upcoming one if nothing is in progress
+	CommandDismiss CommandAction = "dismiss"
+	// CommandSnooze hides the meetings from the displays for a while
+	CommandSnooze CommandAction = "snooze"
+	// CommandClear drops all the overrides
+	CommandClear CommandAction = "clear"
+	// CommandRefresh fetches the calendar immediately, not an override itself
+	CommandRefresh CommandAction = "refresh"
+)
+
+// Command is the manual override request, e.g.:
+//
+//	{"action": "busy", "for": "30m"}
+//	{"action": "busy", "until": "15:00"}
+//	{"action": "free"}
+//	{"action": "dismiss"}
+//	{"action": "snooze", "for": "15m"}
+//	{"action": "clear"}
+type Command struct {
+	Action CommandAction
+	For    time.Duration
+	// Until is either "15:04" in the overrides location or RFC3339 time
+	Until string
+}
+
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Action CommandAction `json:"action"`
+		For    string        `json:"for"`
+		Until  string        `json:"until"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	c.Action = raw.Action
+	c.Until = raw.Until
+	c.For = 0
+	if raw.For != "" {
+		d, err := time.ParseDuration(raw.For)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", raw.For, err)
+		}
+
+		c.For = d
+	}
+
+	return nil
+}
+
+func ParseCommand(payload []byte) (Command, error) {
+	var cmd Command
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		return Command{}, fmt.Errorf("invalid command: %w", err)
+	}
+
+	return cmd, nil
+}
+
+type OverridesConfig struct {
+	Storage *storage.Storage
+	// StorageKey to keep overrides at
+	StorageKey string
+	// Location to resolve the "until" time of day in
+	Location *time.Location
+}
+
+// Overrides keeps the manual intervals merged with the calendar events
+type Overrides struct {
+	mu      sync.Mutex
+	storage *storage.Storage
+	key     string
+	loc     *time.Location
+	state   overridesState
+}
+
+type overridesState struct {
+	// Busy are manual intervals treated as meetings
+	Busy []Interval `json:"busy"`
+	// Masks hide the calendar events ended manually
+	Masks []mask `json:"masks"`
+	// SnoozedUntil hides the meetings from the displays until the given time
+	SnoozedUntil time.Time `json:"snoozedUntil"`
+}
+
+// mask hides the calendar events by their IDs until the last of them ends
+type mask struct {
+	IDs []int     `json:"ids"`
+	End time.Time `json:"end"`
+}
+
+func NewOverrides(cfg OverridesConfig) (*Overrides, error) {
+	o := &Overrides{
+		storage: cfg.Storage,
+		key:     cfg.StorageKey,
+		loc:     cfg.Location,
+	}
+
+	if o.key == "" {
+		o.key = DefaultOverridesStorageKey
+	}
+
+	if o.loc == nil {
+		o.loc = time.Local
+	}
+
+	if o.storage != nil {
+		if _, err := o.storage.Get(o.key, &o.state); err != nil {
+			return nil, fmt.Errorf("load overrides: %w", err)
+		}
+	}
+
+	return o, nil
+}
+
+// Apply applies the command at the given moment and persists the result.
+// The targets are the events the free and dismiss actions end, the manual busy ones included.
+func (o *Overrides) Apply(cmd Command, now time.Time, targets []calendar.Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.prune(now)
+	switch cmd.Action {
+	case CommandBusy:
+		end, err := o.until(cmd, now)
+		if err != nil {
+			return err
+		}
+
+		o.state.Busy = append(o.state.Busy, Interval{
+			Start: now,
+			End:   end,
+		})
+	case CommandFree, CommandDismiss:
+		o.mask(targets)
+	case CommandSnooze:
+		end, err := o.until(cmd, now)
+		if err != nil {
+			return err
+		}
+
+		o.state.SnoozedUntil = end
+	case CommandClear:
+		o.state = overridesState{}
+	default:
+		return fmt.Errorf("unknown action: %q", cmd.Action)
+	}
+
+	return o.save()
+}
+
+// Merge returns the calendar events with the overrides applied, sorted by the start time
+func (o *Overrides) Merge(events []calendar.Event) []calendar.Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.state.Busy) == 0 && len(o.state.Masks) == 0 {
+		return events
+	}
+
+	out := make([]calendar.Event, 0, len(events)+len(o.state.Busy))
+	for _, e := range events {
+		if o.masked(e) {
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	for _, b := range o.state.Busy {
+		out = append(out, calendar.Event{
+			Start: b.Start,
+			End:   b.End,
+		})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out
+}
+
+// SnoozedUntil returns the snooze end if the displays are snoozed at the given moment
+func (o *Overrides) SnoozedUntil(now time.Time) (time.Time, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.state.SnoozedUntil, o.state.SnoozedUntil.After(now)
+}
+
+// mask hides the calendar events and drops the manual busy intervals among the targets
+func (o *Overrides) mask(targets []calendar.Event) {
+	var m mask
+	for _, e := range targets {
+		if e.ID != 0 {
+			m.IDs = append(m.IDs, e.ID)
+			if e.End.After(m.End) {
+				m.End = e.End
+			}
+			continue
+		}
+
+		busy := o.state.Busy[:0]
+		for _, b := range o.state.Busy {
+			if !b.Start.Equal(e.Start) || !b.End.Equal(e.End) {
+				busy = append(busy, b)
+			}
+		}
+		o.state.Busy = busy
+	}
+
+	if len(m.IDs) > 0 {
+		o.state.Masks = append(o.state.Masks, m)
+	}
+}
+
+func (o *Overrides) masked(e calendar.Event) bool {
+	for _, m := range o.state.Masks {
+		for _, id := range m.IDs {
+			if id == e.ID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (o *Overrides) until(cmd Command, now time.Time) (time.Time, error) {
+	switch {
+	case cmd.For > 0 && cmd.Until != "":
+		return time.Time{}, errors.New("only one of .For and .Until is allowed")
+	case cmd.For > 0:
+		return now.Add(cmd.For), nil
+	case cmd.Until == "":
+		return time.Time{}, errors.New(".For or .Until is required")
+	}
+
+	if t, err := time.Parse(time.RFC3339, cmd.Until); err == nil {
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("until is in the past: %s", cmd.Until)
+		}
+
+		return t, nil
+	}
+
+	clock, err := time.Parse("15:04", cmd.Until)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid until %q: %w", cmd.Until, err)
+	}
+
+	local := now.In(o.loc)
+	y, m, d := local.Date()
+	end := time.Date(y, m, d, clock.Hour(), clock.Minute(), 0, 0, o.loc)
+	if !end.After(now) {
+		// "until 09:00" in the evening means tomorrow morning
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end, nil
+}
+
+func (o *Overrides) prune(now time.Time) {
+	alive := func(in []Interval) []Interval {
+		out := in[:0]
+		for _, i := range in {
+			if i.End.After(now) {
+				out = append(out, i)
+			}
+		}
+		return out
+	}
+
+	o.state.Busy = alive(o.state.Busy)
+	masks := o.state.Masks[:0]
+	for _, m := range o.state.Masks {
+		if m.End.After(now) {
+			masks = append(masks, m)
+		}
+	}
+	o.state.Masks = masks
+	if !o.state.SnoozedUntil.After(now) {
+		o.state.SnoozedUntil = time.Time{}
+	}
+}
+
+func (o *Overrides) save() error {
+	if o.storage == nil {
+		return nil
+	}
+
+	return o.storage.Set(o.key, o.state)
+}
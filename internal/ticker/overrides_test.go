@@ -0,0 +1,273 @@
+package ticker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/calendar"
+	"github.com/buglloc/aweeting/internal/storage"
+)
+
+func TestOverrides(t *testing.T) {
+	// 1987-04-06 is Monday
+	day := time.Date(1987, 4, 6, 0, 0, 0, 0, time.UTC)
+	at := day.Add(12 * time.Hour)
+	meeting := calendar.Event{
+		ID:    1,
+		Start: day.Add(11 * time.Hour),
+		End:   day.Add(13 * time.Hour),
+	}
+	later := calendar.Event{
+		ID:    2,
+		Start: day.Add(14 * time.Hour),
+		End:   day.Add(15 * time.Hour),
+	}
+
+	cases := []struct {
+		name     string
+		commands []string
+		expected Interval
+		err      bool
+	}{
+		{
+			name: "calendar",
+			expected: Interval{
+				Start: meeting.Start,
+				End:   meeting.End,
+			},
+		},
+		{
+			name:     "busy-for",
+			commands: []string{`{"action": "free"}`, `{"action": "busy", "for": "30m"}`},
+			expected: Interval{
+				Start: at,
+				End:   at.Add(30 * time.Minute),
+			},
+		},
+		{
+			name:     "busy-until-merged",
+			commands: []string{`{"action": "busy", "until": "13:30"}`},
+			expected: Interval{
+				Start: meeting.Start,
+				End:   later.End,
+			},
+		},
+		{
+			name:     "free",
+			commands: []string{`{"action": "free"}`},
+			expected: Interval{
+				Start: later.Start,
+				End:   later.End,
+			},
+		},
+		{
+			name:     "free-busy",
+			commands: []string{`{"action": "busy", "for": "30m"}`, `{"action": "free"}`},
+			expected: Interval{
+				Start: later.Start,
+				End:   later.End,
+			},
+		},
+		{
+			name:     "free-later",
+			commands: []string{`{"action": "busy", "until": "14:30"}`, `{"action": "free"}`},
+			expected: Interval{
+				Start: later.Start,
+				End:   later.End,
+			},
+		},
+		{
+			name:     "dismiss",
+			commands: []string{`{"action": "dismiss"}`},
+			expected: Interval{
+				Start: later.Start,
+				End:   later.End,
+			},
+		},
+		{
+			name:     "dismiss-all",
+			commands: []string{`{"action": "dismiss"}`, `{"action": "dismiss"}`},
+			expected: Interval{},
+		},
+		{
+			name:     "clear",
+			commands: []string{`{"action": "free"}`, `{"action": "clear"}`},
+			expected: Interval{
+				Start: meeting.Start,
+				End:   meeting.End,
+			},
+		},
+		{
+			name:     "unknown",
+			commands: []string{`{"action": "lunch"}`},
+			err:      true,
+		},
+		{
+			name:     "no-duration",
+			commands: []string{`{"action": "busy"}`},
+			err:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := NewOverrides(OverridesConfig{
+				Location: time.UTC,
+			})
+			require.NoError(t, err)
+
+			i := NewIntervaler(time.Hour).WithOverrides(o)
+			i.UpdateEvents([]calendar.Event{meeting, later})
+			for _, raw := range tc.commands {
+				cmd, err := ParseCommand([]byte(raw))
+				require.NoError(t, err)
+
+				err = i.Apply(cmd, at)
+				if tc.err {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tc.expected, i.CurrentAt(at))
+		})
+	}
+}
+
+func TestOverrides_dismissUpcoming(t *testing.T) {
+	at := time.Unix(544672800, 0)
+	planning := calendar.Event{
+		ID:    1,
+		Start: at.Add(20 * time.Minute),
+		End:   at.Add(50 * time.Minute),
+	}
+	review := calendar.Event{
+		ID:    2,
+		Start: at.Add(30 * time.Minute),
+		End:   at.Add(60 * time.Minute),
+	}
+	retro := calendar.Event{
+		ID:    3,
+		Start: at.Add(2 * time.Hour),
+		End:   at.Add(3 * time.Hour),
+	}
+
+	o, err := NewOverrides(OverridesConfig{})
+	require.NoError(t, err)
+
+	i := NewIntervaler(0).WithOverrides(o)
+	i.UpdateEvents([]calendar.Event{planning, review, retro})
+	require.Equal(t, at.Add(20*time.Minute), i.CurrentAt(at).Start)
+
+	// the whole upcoming interval is skipped, the following one stays
+	require.NoError(t, i.Apply(Command{Action: CommandDismiss}, at))
+	cur := i.CurrentAt(at)
+	require.Equal(t, retro.Start, cur.Start)
+	require.Equal(t, retro.End, cur.End)
+
+	// the skipped meetings don't come back when their time comes
+	cur = i.CurrentAt(at.Add(25 * time.Minute))
+	require.Equal(t, retro.Start, cur.Start)
+
+	require.NoError(t, i.Apply(Command{Action: CommandDismiss}, at))
+	require.Error(t, i.Apply(Command{Action: CommandDismiss}, at))
+}
+
+func TestOverrides_clear(t *testing.T) {
+	day := time.Date(1987, 4, 6, 0, 0, 0, 0, time.UTC)
+	at := day.Add(90 * time.Minute)
+	meeting := calendar.Event{
+		ID:    1,
+		Start: day.Add(1 * time.Hour),
+		End:   day.Add(2 * time.Hour),
+	}
+	later := calendar.Event{
+		ID:    2,
+		Start: day.Add(5 * time.Hour),
+		End:   day.Add(6 * time.Hour),
+	}
+
+	o, err := NewOverrides(OverridesConfig{})
+	require.NoError(t, err)
+
+	i := NewIntervaler(0).WithOverrides(o)
+	i.UpdateEvents([]calendar.Event{meeting, later})
+
+	require.NoError(t, i.Apply(Command{Action: CommandFree}, at))
+	require.Equal(t, later.Start, i.CurrentAt(at).Start)
+
+	// the masked meeting is back once the overrides are cleared
+	require.NoError(t, i.Apply(Command{Action: CommandClear}, at))
+	require.Equal(t, meeting.Start, i.CurrentAt(at).Start)
+}
+
+func TestOverrides_persist(t *testing.T) {
+	at := time.Unix(544672800, 0)
+	path := filepath.Join(t.TempDir(), "state.json")
+	newOverrides := func() *Overrides {
+		s, err := storage.NewStorage(path)
+		require.NoError(t, err)
+
+		o, err := NewOverrides(OverridesConfig{
+			Storage: s,
+		})
+		require.NoError(t, err)
+		return o
+	}
+
+	require.NoError(t, newOverrides().Apply(Command{Action: CommandBusy, For: time.Hour}, at, nil))
+
+	events := newOverrides().Merge(nil)
+	require.Len(t, events, 1)
+	require.True(t, at.Add(time.Hour).Equal(events[0].End))
+}
+
+func TestOverrides_mask(t *testing.T) {
+	at := time.Unix(544672800, 0)
+	meeting := calendar.Event{
+		ID:    1,
+		Start: at.Add(-10 * time.Minute),
+		End:   at.Add(20 * time.Minute),
+	}
+	// the same series event the next day must stay visible
+	tomorrow := calendar.Event{
+		ID:    2,
+		Start: meeting.Start.Add(24 * time.Hour),
+		End:   meeting.End.Add(24 * time.Hour),
+	}
+
+	o, err := NewOverrides(OverridesConfig{})
+	require.NoError(t, err)
+	require.NoError(t, o.Apply(Command{Action: CommandFree}, at, []calendar.Event{meeting}))
+	require.Equal(t, []calendar.Event{tomorrow}, o.Merge([]calendar.Event{meeting, tomorrow}))
+
+	// the mask is dropped once the masked events end
+	require.NoError(t, o.Apply(Command{Action: CommandSnooze, For: time.Minute}, meeting.End, nil))
+	require.Len(t, o.state.Masks, 0)
+}
+
+func TestOverrides_snooze(t *testing.T) {
+	at := time.Unix(544672800, 0)
+	o, err := NewOverrides(OverridesConfig{})
+	require.NoError(t, err)
+
+	require.NoError(t, o.Apply(Command{Action: CommandSnooze, For: 15 * time.Minute}, at, nil))
+
+	until, ok := o.SnoozedUntil(at.Add(10 * time.Minute))
+	require.True(t, ok)
+	require.Equal(t, at.Add(15*time.Minute), until)
+
+	_, ok = o.SnoozedUntil(at.Add(15 * time.Minute))
+	require.False(t, ok)
+
+	require.NoError(t, o.Apply(Command{Action: CommandSnooze, For: 15 * time.Minute}, at, nil))
+	require.NoError(t, o.Apply(Command{Action: CommandClear}, at, nil))
+	_, ok = o.SnoozedUntil(at)
+	require.False(t, ok)
+
+	require.Error(t, o.Apply(Command{Action: CommandSnooze}, at, nil))
+}
@@ -0,0 +1,223 @@
+package ticker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/aweeting/internal/calendar"
+)
+
+var _ Ticker = (*SimTicker)(nil)
+
+type SimTickerConfig struct {
+	Jitter       time.Duration
+	PreviewLimit time.Duration
+	TickInterval time.Duration
+	From         time.Time
+	To           time.Time
+	// Speed is a virtual-to-real time multiplier, zero means "as fast as possible"
+	Speed float64
+	// Marks are offsets before the event start when an extra tick must be fired
+	Marks []time.Duration
+	// EndMarks are offsets before the interval end when an extra tick must be fired
+	EndMarks []time.Duration
+	States   MachineConfig
+	// Classifier assigns the event classes, all events are meetings if nil
+	Classifier *Classifier
+}
+
+// SimTicker walks the [From, To] range with a virtual clock and calls the handler
+// at each tick and at each event transition.
+type SimTicker struct {
+	cal          calendar.Calendar
+	ctx          context.Context
+	cancelCtx    context.CancelFunc
+	done         chan struct{}
+	interval     *Intervaler
+	machine      *Machine
+	previewLimit time.Duration
+	tickInterval time.Duration
+	from         time.Time
+	to           time.Time
+	speed        float64
+	marks        []time.Duration
+	endMarks     []time.Duration
+}
+
+func NewSimTicker(cal calendar.Calendar, cfg SimTickerConfig) (*SimTicker, error) {
+	if cfg.TickInterval <= 0 {
+		return nil, errors.New(".TickInterval is required")
+	}
+
+	if !cfg.From.Before(cfg.To) {
+		return nil, fmt.Errorf("invalid range: %s -> %s", cfg.From, cfg.To)
+	}
+
+	if cfg.Speed < 0 {
+		return nil, fmt.Errorf("invalid speed: %f", cfg.Speed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SimTicker{
+		cal:          cal,
+		ctx:          ctx,
+		cancelCtx:    cancel,
+		done:         make(chan struct{}),
+		interval:     NewIntervaler(cfg.Jitter).WithClassifier(cfg.Classifier),
+		machine:      NewMachine(cfg.States),
+		previewLimit: cfg.PreviewLimit,
+		tickInterval: cfg.TickInterval,
+		from:         cfg.From,
+		to:           cfg.To,
+		speed:        cfg.Speed,
+		marks:        cfg.Marks,
+		endMarks:     cfg.EndMarks,
+	}, nil
+}
+
+func (t *SimTicker) Start(handler Handler) error {
+	defer close(t.done)
+
+	events, err := t.cal.EventsBetween(t.ctx, calendar.TimeBound{
+		Start: t.from,
+		End:   t.to.Add(t.previewLimit),
+	})
+	if err != nil {
+		return fmt.Errorf("fetch events: %w", err)
+	}
+	log.Info().Int("count", len(events)).Msg("got calendar events")
+
+	t.interval.UpdateEvents(events)
+
+	prev := t.from
+	for _, now := range t.steps(events) {
+		if err := t.sleep(now.Sub(prev)); err != nil {
+			return nil
+		}
+		prev = now
+
+		// events are fetched once for the whole range, so they never get stale
+		cur, next := t.interval.CurrentWithNextAt(now)
+		event := cur.ToEvent(now)
+		event.Next = next
+		event.Intervals = t.interval.IntervalsAt(now)
+		event = t.machine.Next(event, now)
+		if err := handler(t.ctx, event); err != nil {
+			log.Error().Time("now", now).Err(err).Msg("tick failed")
+		}
+	}
+
+	return nil
+}
+
+// Tick does nothing, since the simulation walks the range on its own
+func (t *SimTicker) Tick(_ context.Context) error {
+	return nil
+}
+
+// Republish does nothing, since the simulation walks the range on its own
+func (t *SimTicker) Republish(_ context.Context) error {
+	return nil
+}
+
+// Refresh does nothing, since the simulation fetches the whole range on start
+func (t *SimTicker) Refresh(_ context.Context) error {
+	return nil
+}
+
+// Execute does nothing, since the simulation replays the calendar as is
+func (t *SimTicker) Execute(_ context.Context, _ Command) error {
+	return nil
+}
+
+func (t *SimTicker) Stop(ctx context.Context) {
+	t.cancelCtx()
+	select {
+	case <-ctx.Done():
+		return
+	case <-t.done:
+		return
+	}
+}
+
+func (t *SimTicker) steps(events []calendar.Event) []time.Time {
+	out := []time.Time{t.from}
+	for now := t.from.Truncate(t.tickInterval); !now.After(t.to); now = now.Add(t.tickInterval) {
+		if !now.After(t.from) {
+			continue
+		}
+
+		out = append(out, now)
+	}
+
+	inRange := func(ts time.Time) bool {
+		return !ts.Before(t.from) && !ts.After(t.to)
+	}
+
+	for _, e := range events {
+		interval := Interval{
+			Start: e.Start,
+			End:   e.End,
+		}
+
+		for _, ts := range t.machine.Transitions(interval) {
+			if inRange(ts) {
+				out = append(out, ts)
+			}
+		}
+
+		for _, m := range t.marks {
+			if ts := e.Start.Add(-m); inRange(ts) {
+				out = append(out, ts)
+			}
+		}
+
+		for _, m := range t.endMarks {
+			if ts := e.End.Add(-m); inRange(ts) {
+				out = append(out, ts)
+			}
+		}
+	}
+
+	wh := t.machine.cfg.WorkingHours
+	for ts := wh.Next(t.from); !ts.IsZero() && inRange(ts); ts = wh.Next(ts) {
+		out = append(out, ts)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Before(out[j])
+	})
+
+	n := 0
+	for i, ts := range out {
+		if i > 0 && ts.Equal(out[n-1]) {
+			continue
+		}
+
+		out[n] = ts
+		n++
+	}
+
+	return out[:n]
+}
+
+func (t *SimTicker) sleep(d time.Duration) error {
+	if t.speed == 0 || d <= 0 {
+		return t.ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(float64(d) / t.speed))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	}
+}
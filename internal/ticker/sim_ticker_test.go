@@ -0,0 +1,112 @@
+package ticker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/aweeting/internal/calendar"
+)
+
+type staticCalendar []calendar.Event
+
+func (c staticCalendar) Events(ctx context.Context, limit time.Duration) ([]calendar.Event, error) {
+	start := time.Now()
+	return c.EventsBetween(ctx, calendar.TimeBound{Start: start, End: start.Add(limit)})
+}
+
+func (c staticCalendar) EventsBetween(_ context.Context, tb calendar.TimeBound) ([]calendar.Event, error) {
+	var out []calendar.Event
+	for _, e := range c {
+		if e.End.Before(tb.Start) || e.Start.After(tb.End) {
+			continue
+		}
+
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+func TestSimTicker(t *testing.T) {
+	day := time.Date(1987, 4, 6, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+
+	cal := staticCalendar{
+		{
+			ID:    1,
+			Start: at(9, 20),
+			End:   at(9, 40),
+		},
+		{
+			ID:    2,
+			Start: at(12, 0),
+			End:   at(13, 0),
+		},
+	}
+
+	ticker, err := NewSimTicker(cal, SimTickerConfig{
+		PreviewLimit: time.Hour,
+		TickInterval: 15 * time.Minute,
+		From:         at(9, 5),
+		To:           at(10, 0),
+		Marks:        []time.Duration{10 * time.Minute},
+		EndMarks:     []time.Duration{5 * time.Minute},
+		States: MachineConfig{
+			UpcomingLimit: time.Hour,
+			StartingSoon:  3 * time.Minute,
+			WorkingHours: WorkingHours{
+				From:     9*time.Hour + 50*time.Minute,
+				To:       18 * time.Hour,
+				Location: time.UTC,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	type tick struct {
+		Now   time.Time
+		State State
+	}
+	var actual []tick
+	err = ticker.Start(func(_ context.Context, e Event) error {
+		actual = append(actual, tick{Now: e.Now, State: e.State})
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []tick{
+		{Now: at(9, 5), State: StateUpcoming},
+		// mark before the start
+		{Now: at(9, 10), State: StateUpcoming},
+		{Now: at(9, 15), State: StateUpcoming},
+		// starting soon transition
+		{Now: at(9, 17), State: StateStartingSoon},
+		{Now: at(9, 20), State: StateOnAir},
+		{Now: at(9, 30), State: StateOnAir},
+		// mark before the end
+		{Now: at(9, 35), State: StateOnAir},
+		{Now: at(9, 40), State: StateOffHours},
+		{Now: at(9, 45), State: StateOffHours},
+		// working hours begin
+		{Now: at(9, 50), State: StateIdle},
+		{Now: at(10, 0), State: StateIdle},
+	}, actual)
+}
+
+func TestSimTicker_invalid(t *testing.T) {
+	from := time.Date(1987, 4, 6, 9, 0, 0, 0, time.UTC)
+
+	_, err := NewSimTicker(staticCalendar{}, SimTickerConfig{From: from, To: from.Add(time.Hour)})
+	require.Error(t, err)
+
+	_, err = NewSimTicker(staticCalendar{}, SimTickerConfig{TickInterval: time.Minute, From: from, To: from})
+	require.Error(t, err)
+
+	_, err = NewSimTicker(staticCalendar{}, SimTickerConfig{TickInterval: time.Minute, From: from, To: from.Add(time.Hour), Speed: -1})
+	require.Error(t, err)
+}
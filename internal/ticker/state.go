@@ -0,0 +1,172 @@
+package ticker
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultUpcomingLimit = 8 * time.Hour
+
+type State string
+
+const (
+	// StateIdle - no meetings within the upcoming limit
+	StateIdle State = "idle"
+	// StateUpcoming - the meeting starts within the upcoming limit
+	StateUpcoming State = "upcoming"
+	// StateStartingSoon - the meeting starts within the starting soon threshold
+	StateStartingSoon State = "startingSoon"
+	// StateOnAir - the meeting is in progress
+	StateOnAir State = "onAir"
+	// StateWrappingUp - the meeting ends within the wrapping up threshold
+	StateWrappingUp State = "wrappingUp"
+	// StateStale - calendar wasn't fetched for too long and there are no known meetings
+	StateStale State = "stale"
+	// StateOffHours - outside the working hours with no upcoming meetings
+	StateOffHours State = "offHours"
+	// StateFocus - the focus time block is in progress
+	StateFocus State = "focus"
+)
+
+// Base returns one of the idle, upcoming, on-air or focus states the state is derived from
+func (s State) Base() State {
+	switch s {
+	case StateUpcoming, StateStartingSoon:
+		return StateUpcoming
+	case StateOnAir, StateWrappingUp:
+		return StateOnAir
+	case StateFocus:
+		return StateFocus
+	default:
+		return StateIdle
+	}
+}
+
+type MachineConfig struct {
+	// Intervals starting later are considered idle
+	UpcomingLimit time.Duration
+	// Upcoming intervals starting within this threshold are "starting soon", zero disables
+	StartingSoon time.Duration
+	// On-air intervals ending within this threshold are "wrapping up", zero disables
+	WrappingUp time.Duration
+	// Events are stale if they weren't fetched for the given duration, zero disables
+	StaleAfter time.Duration
+	// Outside the working hours idle becomes "off hours", zero disables
+	WorkingHours WorkingHours
+}
+
+// Machine derives the event state and tracks transitions between them
+type Machine struct {
+	mu   sync.Mutex
+	cfg  MachineConfig
+	prev State
+	// from is the replaced machine the state is taken from on the first event
+	from *Machine
+}
+
+func NewMachine(cfg MachineConfig) *Machine {
+	if cfg.UpcomingLimit <= 0 {
+		cfg.UpcomingLimit = DefaultUpcomingLimit
+	}
+
+	return &Machine{
+		cfg: cfg,
+	}
+}
+
+// Continue makes the machine continue from the last state of the replaced one, e.g. on config reload,
+// so the transitions are neither repeated nor missed
+func (m *Machine) Continue(prev *Machine) *Machine {
+	if prev == m {
+		return m
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.from = prev
+	return m
+}
+
+// Prev returns the state of the last event
+func (m *Machine) Prev() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.prev
+}
+
+// Next fills the event state and the previous one, fetchedAt is the time of the last successful calendar fetch
+func (m *Machine) Next(event Event, fetchedAt time.Time) Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.from != nil {
+		if m.prev == "" {
+			m.prev = m.from.Prev()
+		}
+		m.from = nil
+	}
+
+	event.State = m.State(event, fetchedAt)
+	event.PrevState = m.prev
+	m.prev = event.State
+	return event
+}
+
+func (m *Machine) State(event Event, fetchedAt time.Time) State {
+	switch {
+	case event.IsZero() || event.ToStart > m.cfg.UpcomingLimit:
+	case event.Class == ClassFocus && !event.Upcoming:
+		return StateFocus
+	case event.Upcoming && event.ToStart <= m.cfg.StartingSoon:
+		return StateStartingSoon
+	case event.Upcoming:
+		return StateUpcoming
+	case event.Left <= m.cfg.WrappingUp:
+		return StateWrappingUp
+	default:
+		return StateOnAir
+	}
+
+	switch {
+	case !m.cfg.WorkingHours.Contains(event.Now):
+		return StateOffHours
+	case m.cfg.StaleAfter > 0 && event.Now.Sub(fetchedAt) >= m.cfg.StaleAfter:
+		return StateStale
+	default:
+		return StateIdle
+	}
+}
+
+// Transitions returns the moments the interval state may change at
+func (m *Machine) Transitions(cur Interval) []time.Time {
+	if cur.IsZero() {
+		return nil
+	}
+
+	out := []time.Time{cur.Start.Add(-m.cfg.UpcomingLimit), cur.Start, cur.End}
+	if m.cfg.StartingSoon > 0 {
+		out = append(out, cur.Start.Add(-m.cfg.StartingSoon))
+	}
+
+	if m.cfg.WrappingUp > 0 {
+		out = append(out, cur.End.Add(-m.cfg.WrappingUp))
+	}
+
+	return out
+}
+
+// NextTransition returns the nearest moment after now the interval-independent state may change at
+func (m *Machine) NextTransition(now, fetchedAt time.Time) time.Time {
+	next := m.cfg.WorkingHours.Next(now)
+	if m.cfg.StaleAfter <= 0 {
+		return next
+	}
+
+	if stale := fetchedAt.Add(m.cfg.StaleAfter); stale.After(now) && (next.IsZero() || stale.Before(next)) {
+		return stale
+	}
+
+	return next
+}
@@ -0,0 +1,268 @@
+package ticker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachine_State(t *testing.T) {
+	// 1987-04-06 is Monday
+	day := time.Date(1987, 4, 6, 0, 0, 0, 0, time.UTC)
+	cfg := MachineConfig{
+		UpcomingLimit: 2 * time.Hour,
+		StartingSoon:  10 * time.Minute,
+		WrappingUp:    5 * time.Minute,
+		StaleAfter:    3 * time.Hour,
+		WorkingHours: WorkingHours{
+			From:     9 * time.Hour,
+			To:       19 * time.Hour,
+			Days:     []time.Weekday{time.Monday},
+			Location: time.UTC,
+		},
+	}
+
+	interval := Interval{
+		Start: day.Add(12 * time.Hour),
+		End:   day.Add(13 * time.Hour),
+	}
+
+	cases := []struct {
+		name      string
+		interval  Interval
+		now       time.Time
+		fetchedAt time.Time
+		expected  State
+	}{
+		{
+			name:     "idle",
+			now:      day.Add(10 * time.Hour),
+			expected: StateIdle,
+		},
+		{
+			name:     "too-far",
+			interval: interval,
+			now:      day.Add(9 * time.Hour),
+			expected: StateIdle,
+		},
+		{
+			name:     "upcoming",
+			interval: interval,
+			now:      day.Add(11 * time.Hour),
+			expected: StateUpcoming,
+		},
+		{
+			name:     "starting-soon",
+			interval: interval,
+			now:      interval.Start.Add(-10 * time.Minute),
+			expected: StateStartingSoon,
+		},
+		{
+			name:     "on-air",
+			interval: interval,
+			now:      interval.Start,
+			expected: StateOnAir,
+		},
+		{
+			name:     "wrapping-up",
+			interval: interval,
+			now:      interval.End.Add(-5 * time.Minute),
+			expected: StateWrappingUp,
+		},
+		{
+			name:     "off-hours",
+			now:      day.Add(20 * time.Hour),
+			expected: StateOffHours,
+		},
+		{
+			name:     "off-day",
+			now:      day.Add(-12 * time.Hour),
+			expected: StateOffHours,
+		},
+		{
+			name: "off-hours-meeting",
+			interval: Interval{
+				Start: day.Add(20 * time.Hour),
+				End:   day.Add(21 * time.Hour),
+			},
+			now:      day.Add(20 * time.Hour),
+			expected: StateOnAir,
+		},
+		{
+			name:      "stale",
+			now:       day.Add(15 * time.Hour),
+			fetchedAt: day.Add(12 * time.Hour),
+			expected:  StateStale,
+		},
+		{
+			name:      "stale-on-air",
+			interval:  interval,
+			now:       interval.Start,
+			fetchedAt: day,
+			expected:  StateOnAir,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fetchedAt := tc.fetchedAt
+			if fetchedAt.IsZero() {
+				fetchedAt = tc.now
+			}
+
+			actual := NewMachine(cfg).State(tc.interval.ToEvent(tc.now), fetchedAt)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestMachine_Next(t *testing.T) {
+	now := time.Unix(544672800, 0)
+	interval := Interval{
+		Start: now.Add(time.Minute),
+		End:   now.Add(time.Hour),
+	}
+
+	m := NewMachine(MachineConfig{})
+	event := m.Next(interval.ToEvent(now), now)
+	require.Equal(t, StateUpcoming, event.State)
+	require.False(t, event.Changed())
+
+	event = m.Next(interval.ToEvent(interval.Start), now)
+	require.Equal(t, StateOnAir, event.State)
+	require.Equal(t, StateUpcoming, event.PrevState)
+	require.True(t, event.Changed())
+}
+
+func TestMachine_Continue(t *testing.T) {
+	now := time.Unix(544672800, 0)
+	interval := Interval{
+		Start: now.Add(-time.Minute),
+		End:   now.Add(time.Hour),
+	}
+
+	prev := NewMachine(MachineConfig{})
+	require.Equal(t, StateOnAir, prev.Next(interval.ToEvent(now), now).State)
+
+	// the replacing machine neither repeats nor misses the transitions
+	m := NewMachine(MachineConfig{WrappingUp: 5 * time.Minute}).Continue(prev)
+	event := m.Next(interval.ToEvent(now.Add(time.Minute)), now)
+	require.Equal(t, StateOnAir, event.PrevState)
+	require.False(t, event.Changed())
+
+	event = m.Next(Interval{}.ToEvent(interval.End), now)
+	require.Equal(t, StateIdle, event.State)
+	require.Equal(t, StateOnAir, event.PrevState)
+}
+
+func TestWorkingHours_Next(t *testing.T) {
+	// 1987-04-06 is Monday
+	day := time.Date(1987, 4, 6, 0, 0, 0, 0, time.UTC)
+	wh := WorkingHours{
+		From:     9 * time.Hour,
+		To:       19 * time.Hour,
+		Days:     []time.Weekday{time.Monday, time.Tuesday},
+		Location: time.UTC,
+	}
+
+	require.Equal(t, day.Add(9*time.Hour), wh.Next(day))
+	require.Equal(t, day.Add(19*time.Hour), wh.Next(day.Add(9*time.Hour)))
+	require.Equal(t, day.Add(33*time.Hour), wh.Next(day.Add(19*time.Hour)))
+	require.Equal(t, day.Add(7*24*time.Hour+9*time.Hour), wh.Next(day.Add(43*time.Hour)))
+	require.True(t, WorkingHours{}.Next(day).IsZero())
+}
+
+func TestWorkingHours_Contains(t *testing.T) {
+	// 2024-03-31 is Sunday, the clocks go 02:00 -> 03:00 in Berlin
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		wh       WorkingHours
+		at       time.Time
+		expected bool
+	}{
+		{
+			name:     "dst-before",
+			wh:       WorkingHours{From: 9 * time.Hour, To: 19 * time.Hour, Location: berlin},
+			at:       time.Date(2024, 3, 31, 8, 59, 0, 0, berlin),
+			expected: false,
+		},
+		{
+			name:     "dst-from",
+			wh:       WorkingHours{From: 9 * time.Hour, To: 19 * time.Hour, Location: berlin},
+			at:       time.Date(2024, 3, 31, 9, 0, 0, 0, berlin),
+			expected: true,
+		},
+		{
+			name:     "dst-to",
+			wh:       WorkingHours{From: 9 * time.Hour, To: 19 * time.Hour, Location: berlin},
+			at:       time.Date(2024, 3, 31, 19, 0, 0, 0, berlin),
+			expected: false,
+		},
+		{
+			name:     "overnight-evening",
+			wh:       WorkingHours{From: 22 * time.Hour, To: 6 * time.Hour, Days: []time.Weekday{time.Friday}, Location: time.UTC},
+			at:       time.Date(1987, 4, 10, 23, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "overnight-morning",
+			wh:       WorkingHours{From: 22 * time.Hour, To: 6 * time.Hour, Days: []time.Weekday{time.Friday}, Location: time.UTC},
+			at:       time.Date(1987, 4, 11, 5, 59, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "overnight-day",
+			wh:       WorkingHours{From: 22 * time.Hour, To: 6 * time.Hour, Days: []time.Weekday{time.Friday}, Location: time.UTC},
+			at:       time.Date(1987, 4, 10, 12, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "overnight-day-off",
+			wh:       WorkingHours{From: 22 * time.Hour, To: 6 * time.Hour, Days: []time.Weekday{time.Friday}, Location: time.UTC},
+			at:       time.Date(1987, 4, 10, 5, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.wh.Contains(tc.at))
+		})
+	}
+}
+
+func TestWorkingHours_NextOvernight(t *testing.T) {
+	// 1987-04-10 is Friday
+	day := time.Date(1987, 4, 10, 0, 0, 0, 0, time.UTC)
+	wh := WorkingHours{
+		From:     22 * time.Hour,
+		To:       6 * time.Hour,
+		Days:     []time.Weekday{time.Thursday, time.Friday},
+		Location: time.UTC,
+	}
+
+	// the Thursday shift ends on Friday morning
+	require.Equal(t, day.Add(6*time.Hour), wh.Next(day))
+	require.Equal(t, day.Add(22*time.Hour), wh.Next(day.Add(6*time.Hour)))
+	require.Equal(t, day.Add(30*time.Hour), wh.Next(day.Add(22*time.Hour)))
+	require.Equal(t, day.Add(6*24*time.Hour+22*time.Hour), wh.Next(day.Add(30*time.Hour)))
+}
+
+func TestWorkingHours_NextDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	wh := WorkingHours{
+		From:     9 * time.Hour,
+		To:       19 * time.Hour,
+		Location: berlin,
+	}
+
+	day := time.Date(2024, 3, 31, 0, 0, 0, 0, berlin)
+	require.Equal(t, time.Date(2024, 3, 31, 9, 0, 0, 0, berlin), wh.Next(day))
+	require.Equal(t, time.Date(2024, 3, 31, 19, 0, 0, 0, berlin), wh.Next(time.Date(2024, 3, 31, 9, 0, 0, 0, berlin)))
+}
@@ -2,24 +2,64 @@ package ticker
 
 import (
 	"context"
+	"strconv"
 	"time"
 )
 
 type Ticker interface {
 	Start(Handler) error
+	// Tick calls the handler with the current event immediately
+	Tick(context.Context) error
+	// Refresh fetches the calendar and ticks
+	Refresh(context.Context) error
+	// Republish ticks forcing the sinks to resend the unchanged state, e.g. after the device reconnect
+	Republish(context.Context) error
+	// Execute applies the manual command and ticks
+	Execute(context.Context, Command) error
 	Stop(context.Context)
 }
 
 type Handler func(ctx context.Context, event Event) error
 
 type Event struct {
-	Upcoming bool
-	ToStart  time.Duration
-	Left     time.Duration
-	StartsAt time.Time
-	EndsAt   time.Time
+	Now       time.Time
+	State     State
+	PrevState State
+	Upcoming  bool
+	ToStart   time.Duration
+	Left      time.Duration
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Class     Class
+	// Summary and Location of the current meeting
+	Summary  string
+	Location string
+	// Private means the summary and location must not be shown
+	Private bool
+	// Next is the meeting interval following the current one, zero if unknown
+	Next Interval
+	// Intervals are the known intervals of all classes ending after now, sorted by start
+	Intervals []Interval
+	// Snoozed means the displays should stay silent for now
+	Snoozed bool
+	// Forced means the sinks must resend the state even if it wasn't changed
+	Forced bool
 }
 
 func (e *Event) IsZero() bool {
 	return e.StartsAt.IsZero()
 }
+
+// Changed reports whether the state was changed since the previous event
+func (e *Event) Changed() bool {
+	return e.PrevState != "" && e.PrevState != e.State
+}
+
+// IntervalID identifies the event interval by its start, since merging with later events may move the end
+func (e *Event) IntervalID() string {
+	if e.IsZero() {
+		return ""
+	}
+
+	return strconv.FormatInt(e.StartsAt.Unix(), 10)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -39,7 +40,12 @@ func (t *Timer) Start(ctx context.Context) {
 		)
 	}
 
-	ctx = log.With().Str("name", t.name).Logger().WithContext(context.Background())
+	// the task must stop with the caller, so only the logger is attached to its ctx
+	l := log.Ctx(ctx)
+	if l.GetLevel() == zerolog.Disabled {
+		l = &log.Logger
+	}
+	ctx = l.With().Str("name", t.name).Logger().WithContext(ctx)
 	t.timer = time.AfterFunc(tick(), func() {
 		now := time.Now()
 		log.Ctx(ctx).Info().Msg("task started")
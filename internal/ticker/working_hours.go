@@ -0,0 +1,96 @@
+package ticker
+
+import "time"
+
+type WorkingHours struct {
+	// From and To are the wall clock offsets since the midnight, To before From means the overnight shift ending the next day
+	From time.Duration
+	To   time.Duration
+	// Days of the week the shifts start at, every day if empty
+	Days     []time.Weekday
+	Location *time.Location
+}
+
+func (w WorkingHours) IsZero() bool {
+	return w.From == 0 && w.To == 0
+}
+
+// Overnight reports whether the shift ends the next day
+func (w WorkingHours) Overnight() bool {
+	return w.To < w.From
+}
+
+func (w WorkingHours) Contains(t time.Time) bool {
+	if w.IsZero() {
+		return true
+	}
+
+	t = t.In(w.location())
+	h, m, s := t.Clock()
+	offset := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second +
+		time.Duration(t.Nanosecond())
+
+	if !w.Overnight() {
+		return w.isWorkDay(t.Weekday()) && offset >= w.From && offset < w.To
+	}
+
+	// the overnight shift started either today or yesterday
+	return w.isWorkDay(t.Weekday()) && offset >= w.From ||
+		w.isWorkDay(w.clock(t, -1, 0).Weekday()) && offset < w.To
+}
+
+// Next returns the nearest working hours boundary after t
+func (w WorkingHours) Next(t time.Time) time.Time {
+	if w.IsZero() {
+		return time.Time{}
+	}
+
+	endDay := 0
+	if w.Overnight() {
+		endDay = 1
+	}
+
+	t = t.In(w.location())
+	// starts from yesterday to catch the end of the overnight shift
+	for d := -1; d <= 7; d++ {
+		if !w.isWorkDay(w.clock(t, d, 0).Weekday()) {
+			continue
+		}
+
+		for _, ts := range []time.Time{w.clock(t, d, w.From), w.clock(t, d+endDay, w.To)} {
+			if ts.After(t) {
+				return ts
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+func (w WorkingHours) isWorkDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clock returns the wall clock time of the day shifted by days, so the DST changes don't move the boundaries
+func (w WorkingHours) clock(t time.Time, days int, offset time.Duration) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, int(offset), w.location())
+}
+
+func (w WorkingHours) location() *time.Location {
+	if w.Location == nil {
+		return time.Local
+	}
+
+	return w.Location
+}